@@ -15,7 +15,10 @@ import (
 	httpSwagger "github.com/swaggo/http-swagger"
 
 	"github.com/CallumClarke65/spotify-analytics/internal/handlers"
+	compareHandlers "github.com/CallumClarke65/spotify-analytics/internal/handlers/compare"
 	graphHandlers "github.com/CallumClarke65/spotify-analytics/internal/handlers/graphs"
+	planHandlers "github.com/CallumClarke65/spotify-analytics/internal/handlers/plans"
+	suggestionHandlers "github.com/CallumClarke65/spotify-analytics/internal/handlers/suggestions"
 	yearHandlers "github.com/CallumClarke65/spotify-analytics/internal/handlers/year"
 	"github.com/CallumClarke65/spotify-analytics/internal/spotifyauth"
 	"github.com/go-chi/chi/v5"
@@ -90,9 +93,34 @@ func main() {
 		r.Post("/year/{year}/suggestions", yearHandlers.SuggestionsFromYearHandler)
 		r.Post("/year/{year}/analysis", yearHandlers.YearAnalysisHandler)
 
+		r.Post("/year/{year}/month/{month}/songsFromPlaylists", yearHandlers.SongsOnPlaylistsFromMonthHandler)
+		r.Post("/year/{year}/month/{month}/likedSongs", yearHandlers.LikedSongsFromMonthHandler)
+		r.Post("/year/{year}/month/{month}/suggestions", yearHandlers.SuggestionsFromMonthHandler)
+		r.Post("/year/{year}/month/{month}/analysis", yearHandlers.MonthAnalysisHandler)
+
+		r.Get("/plans/{planId}", planHandlers.GetPlanHandler)
+		r.Post("/plans/{planId}/apply", planHandlers.ApplyPlanHandler)
+
+		r.Get("/suggestions/defaults", suggestionHandlers.GetSuggestionDefaultsHandler)
+		r.Put("/suggestions/defaults", suggestionHandlers.PutSuggestionDefaultsHandler)
+		r.Post("/suggestions/feedback", suggestionHandlers.PostSuggestionFeedbackHandler)
+
+		r.Get("/compare/years", compareHandlers.GetYearComparisonHandler)
+		r.Get("/compare/years/chart", compareHandlers.GetYearComparisonChartHandler)
+		r.Get("/compare/years/chart/data", compareHandlers.GetYearComparisonChartDataHandler)
+
 		r.Get("/graphs/topTracksByYear", graphHandlers.GetTopTracksByYearHandler)
+		r.Get("/graphs/topTracksByYear/data", graphHandlers.GetTopTracksByYearDataHandler)
 		r.Get("/graphs/topTrackHeatmap", graphHandlers.GetTopTracksYearPopularityHeatmapHandler)
+		r.Get("/graphs/topTrackHeatmap/data", graphHandlers.GetTopTracksYearPopularityHeatmapDataHandler)
 		r.Get("/graphs/playlistTracksByYear", graphHandlers.GetPlaylistTracksYearGraphHandler)
+		r.Get("/graphs/playlistTracksByYear/data", graphHandlers.GetPlaylistTracksYearGraphDataHandler)
+		r.Get("/graphs/genres", graphHandlers.GetGenresGraphHandler)
+		r.Get("/graphs/genres/data", graphHandlers.GetGenresGraphDataHandler)
+		r.Get("/graphs/libraryGrowth", graphHandlers.GetLibraryGrowthGraphHandler)
+		r.Get("/graphs/libraryGrowth/data", graphHandlers.GetLibraryGrowthGraphDataHandler)
+		r.Get("/graphs/topArtists", graphHandlers.GetTopArtistsGraphHandler)
+		r.Get("/graphs/topArtists/data", graphHandlers.GetTopArtistsGraphDataHandler)
 	})
 
 	logger.Info("Server started",
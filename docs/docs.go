@@ -15,37 +15,132 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/me": {
+        "/compare/years": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Returns information about the currently authenticated Spotify user",
+                "description": "Counts the liked and on-playlist tracks released in each year, lists the artists found in both years and those unique to each, and the change in average popularity from a to b.\nOnly the playlists picked by the selection params are counted, and those playlists are listed in playlists_used.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "compare"
+                ],
+                "summary": "Compare two release years",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "First year",
+                        "name": "a",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Second year",
+                        "name": "b",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Count remasters and compilations towards the year the song was first released",
+                        "name": "resolve_original_year",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Only use these playlists",
+                        "name": "include_playlist_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Never use these playlists",
+                        "name": "exclude_playlist_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Only use playlists whose name matches one of these regular expressions",
+                        "name": "include_name_pattern",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Skip playlists whose name matches one of these regular expressions",
+                        "name": "exclude_name_pattern",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Skip playlists whose name contains one of these, ignoring case",
+                        "name": "exclude_name_substring",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "me, others or a Spotify user ID",
+                        "name": "owner",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only use collaborative (true) or non-collaborative (false) playlists",
+                        "name": "collaborative",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Skip playlists with fewer tracks",
+                        "name": "min_tracks",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Skip playlists with more tracks",
+                        "name": "max_tracks",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Get current Spotify user",
                 "responses": {
                     "200": {
-                        "description": "Spotify user info",
+                        "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/services.YearComparison"
                         }
                     },
-                    "401": {
-                        "description": "Spotify client missing in context",
+                    "400": {
+                        "description": "Invalid year or playlist selection",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Failed to fetch Spotify user",
+                        "description": "Failed to fetch library",
                         "schema": {
                             "type": "string"
                         }
@@ -53,77 +148,164 @@ const docTemplate = `{
                 }
             }
         },
-        "/ping": {
+        "/compare/years/chart": {
             "get": {
-                "description": "Returns service health and Go runtime memory statistics",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "health"
-                ],
-                "summary": "Ping / health check",
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/handlers.PingResponse"
-                        }
-                    }
-                }
-            }
-        },
-        "/year/{year}/analysis": {
-            "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Combines tracks from playlists and liked songs, fetches suggestions, optionally saves JSON, and optionally creates Spotify playlists.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Side-by-side bars of each year's liked, on-playlist and total tracks, and artists. Rendered charts are cached for 10 minutes per user, parameters and format.",
                 "produces": [
-                    "application/json"
+                    "image/png",
+                    "image/svg+xml",
+                    "image/jpeg"
                 ],
                 "tags": [
-                    "year"
+                    "compare"
                 ],
-                "summary": "Perform full year analysis",
+                "summary": "Chart comparing two release years",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Year to analyze",
-                        "name": "year",
-                        "in": "path",
+                        "description": "First year",
+                        "name": "a",
+                        "in": "query",
                         "required": true
                     },
                     {
-                        "description": "Request body",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/yearHandlers.YearAnalysisRequestBody"
-                        }
+                        "type": "integer",
+                        "description": "Second year",
+                        "name": "b",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Count remasters and compilations towards the year the song was first released",
+                        "name": "resolve_original_year",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Only use these playlists",
+                        "name": "include_playlist_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Never use these playlists",
+                        "name": "exclude_playlist_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Only use playlists whose name matches one of these regular expressions",
+                        "name": "include_name_pattern",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Skip playlists whose name matches one of these regular expressions",
+                        "name": "exclude_name_pattern",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Skip playlists whose name contains one of these, ignoring case",
+                        "name": "exclude_name_substring",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "me, others or a Spotify user ID",
+                        "name": "owner",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only use collaborative (true) or non-collaborative (false) playlists",
+                        "name": "collaborative",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Skip playlists with fewer tracks",
+                        "name": "min_tracks",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Skip playlists with more tracks",
+                        "name": "max_tracks",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "png (default), svg or jpeg; overrides the Accept header",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Width in pixels, 200-4096 (default 1280)",
+                        "name": "width",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Height in pixels, 200-4096 (default 720)",
+                        "name": "height",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "light (default), dark, vivid-light, vivid-dark, nature-light, nature-dark, grafana, ant, retro, ocean, slate, gray, winter, spring, summer or fall",
+                        "name": "theme",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Axis text size, 6-48; titles are drawn larger",
+                        "name": "font_size",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/yearHandlers.YearAnalysisResponse"
+                            "type": "file"
                         }
                     },
                     "400": {
-                        "description": "Invalid year or JSON body",
+                        "description": "Invalid year, playlist selection, format or chart option",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Failed to fetch tracks or create playlists",
+                        "description": "Failed to fetch library or render chart",
                         "schema": {
                             "type": "string"
                         }
@@ -131,60 +313,132 @@ const docTemplate = `{
                 }
             }
         },
-        "/year/{year}/likedSongs": {
-            "post": {
+        "/compare/years/chart/data": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Returns a list of liked tracks filtered by year. Optionally saves the results if SaveObject=true.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "The labels and per-year series /compare/years/chart draws.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "year"
+                    "compare"
                 ],
-                "summary": "Get liked songs from a specific year",
+                "summary": "Data behind the year comparison chart",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Year to filter by",
-                        "name": "year",
-                        "in": "path",
+                        "description": "First year",
+                        "name": "a",
+                        "in": "query",
                         "required": true
                     },
                     {
-                        "description": "Request body",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/yearHandlers.LikedSongsBody"
-                        }
+                        "type": "integer",
+                        "description": "Second year",
+                        "name": "b",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Count remasters and compilations towards the year the song was first released",
+                        "name": "resolve_original_year",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Only use these playlists",
+                        "name": "include_playlist_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Never use these playlists",
+                        "name": "exclude_playlist_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Only use playlists whose name matches one of these regular expressions",
+                        "name": "include_name_pattern",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Skip playlists whose name matches one of these regular expressions",
+                        "name": "exclude_name_pattern",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Skip playlists whose name contains one of these, ignoring case",
+                        "name": "exclude_name_substring",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "me, others or a Spotify user ID",
+                        "name": "owner",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only use collaborative (true) or non-collaborative (false) playlists",
+                        "name": "collaborative",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Skip playlists with fewer tracks",
+                        "name": "min_tracks",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Skip playlists with more tracks",
+                        "name": "max_tracks",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/services.TrackInfo"
-                            }
+                            "$ref": "#/definitions/services.BarChartData"
                         }
                     },
                     "400": {
-                        "description": "Invalid year or JSON body",
+                        "description": "Invalid year or playlist selection",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Failed to fetch tracks",
+                        "description": "Failed to fetch library",
                         "schema": {
                             "type": "string"
                         }
@@ -192,193 +446,3063 @@ const docTemplate = `{
                 }
             }
         },
-        "/year/{year}/songsFromPlaylists": {
-            "post": {
+        "/graphs/genres": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Returns tracks from all playlists, excluding ones with ignored substrings. Optionally saves results if SaveObject=true.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Pie, donut or bar chart of the genres of the artists on the user's top tracks, liked songs or a playlist. Each artist counts once towards each of their genres, or with weight=tracks each track counts once towards each genre of its artists. Genres past the top N are folded into \"other\". Rendered charts are cached for 10 minutes per user, parameters and format.",
                 "produces": [
-                    "application/json"
+                    "image/png",
+                    "image/svg+xml",
+                    "image/jpeg"
                 ],
                 "tags": [
-                    "year"
+                    "graphs"
                 ],
-                "summary": "Get tracks from user playlists filtered by year",
+                "summary": "Chart of the genres in the user's music",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "top (default), liked or playlist",
+                        "name": "source",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "short_term, medium_term or long_term; required for the top source",
+                        "name": "time_range",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Playlist ID; required for the playlist source",
+                        "name": "playlist_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "artists (default) or tracks",
+                        "name": "weight",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
-                        "description": "Year to filter by",
-                        "name": "year",
-                        "in": "path",
-                        "required": true
+                        "description": "Genres shown before the rest are folded into other, 1-50 (default 10)",
+                        "name": "top",
+                        "in": "query"
                     },
                     {
-                        "description": "Request body",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/yearHandlers.SongsOnPlaylistsFromYearRequestBody"
-                        }
+                        "type": "string",
+                        "description": "pie (default), donut or bar",
+                        "name": "chart",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "png (default), svg or jpeg; overrides the Accept header",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Width in pixels, 200-4096 (default 1280)",
+                        "name": "width",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Height in pixels, 200-4096 (default 720)",
+                        "name": "height",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "light (default), dark, vivid-light, vivid-dark, nature-light, nature-dark, grafana, ant, retro, ocean, slate, gray, winter, spring, summer or fall",
+                        "name": "theme",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Label text size, 6-48; titles are drawn larger",
+                        "name": "font_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)",
+                        "name": "dedupe",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/services.TrackInfo"
-                            }
+                            "type": "file"
                         }
                     },
                     "400": {
-                        "description": "Invalid year or JSON body",
+                        "description": "Invalid parameter",
                         "schema": {
-                            "type": "string"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Playlist not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     },
                     "500": {
-                        "description": "Failed to fetch tracks",
+                        "description": "Failed to fetch tracks or render chart",
                         "schema": {
-                            "type": "string"
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
                 }
             }
         },
-        "/year/{year}/suggestions": {
-            "post": {
+        "/graphs/genres/data": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Returns a list of suggested tracks for the given year. Optionally saves results if SaveObject=true.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "The genres and counts /graphs/genres draws, largest first with \"other\" last.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "year"
+                    "graphs"
                 ],
-                "summary": "Get suggested tracks from a specific year",
+                "summary": "Data behind the genres chart",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "top (default), liked or playlist",
+                        "name": "source",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "short_term, medium_term or long_term; required for the top source",
+                        "name": "time_range",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Playlist ID; required for the playlist source",
+                        "name": "playlist_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "artists (default) or tracks",
+                        "name": "weight",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
-                        "description": "Year to get suggestions for",
-                        "name": "year",
-                        "in": "path",
-                        "required": true
+                        "description": "Genres listed before the rest are folded into other, 1-50 (default 10)",
+                        "name": "top",
+                        "in": "query"
                     },
                     {
-                        "description": "Request body",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
+                        "type": "string",
+                        "description": "Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)",
+                        "name": "dedupe",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/yearHandlers.SuggestionsFromYearRequestBody"
+                            "$ref": "#/definitions/services.GenreData"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameter",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Playlist not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch tracks",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/graphs/libraryGrowth": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Line chart of how many tracks were in the user's liked songs, and optionally each playlist, by the end of each month, going by when each track was added. Only tracks still there are counted. Bars against the right axis show the tracks added each month across every line. Rendered charts are cached for 10 minutes per user, parameters and format.",
+                "produces": [
+                    "image/png",
+                    "image/svg+xml",
+                    "image/jpeg"
+                ],
+                "tags": [
+                    "graphs"
+                ],
+                "summary": "Chart of how the user's library grew",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Include the liked songs (default true)",
+                        "name": "liked",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Playlists to add a line for, up to 10; repeat the param or separate with commas",
+                        "name": "playlist_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Show the tracks added each month as bars (default true)",
+                        "name": "added",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "png (default), svg or jpeg; overrides the Accept header",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Width in pixels, 200-4096 (default 1280)",
+                        "name": "width",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Height in pixels, 200-4096 (default 720)",
+                        "name": "height",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "light (default), dark, vivid-light, vivid-dark, nature-light, nature-dark, grafana, ant, retro, ocean, slate, gray, winter, spring, summer or fall",
+                        "name": "theme",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Axis text size, 6-48; titles are drawn larger",
+                        "name": "font_size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameter",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Playlist not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch tracks or render chart",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
+                }
+            }
+        },
+        "/graphs/libraryGrowth/data": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The months, and for the liked songs and each playlist the tracks added each month and the running total, that /graphs/libraryGrowth draws.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "graphs"
+                ],
+                "summary": "Data behind the library growth chart",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Include the liked songs (default true)",
+                        "name": "liked",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Playlists to add a series for, up to 10; repeat the param or separate with commas",
+                        "name": "playlist_id",
+                        "in": "query"
+                    }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/services.TrackInfo"
+                            "$ref": "#/definitions/services.LibraryGrowthData"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameter",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Playlist not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch tracks",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
                             }
                         }
+                    }
+                }
+            }
+        },
+        "/graphs/playlistTracksByYear": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Bar chart of how many of the playlist's tracks were released in each year or decade. Given several playlists, each gets its own bars and is named in the legend; percent compares playlists of different sizes. Rendered charts are cached for 10 minutes per user, parameters and format.",
+                "produces": [
+                    "image/png",
+                    "image/svg+xml",
+                    "image/jpeg"
+                ],
+                "tags": [
+                    "graphs"
+                ],
+                "summary": "Chart of playlists' tracks by release year",
+                "parameters": [
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Playlists to chart, up to 10; repeat the param or separate with commas",
+                        "name": "playlist_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "year (default) or decade",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Show each playlist's bars as a percentage of its tracks",
+                        "name": "percent",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Gap between Y axis labels, in tracks or percent",
+                        "name": "y_unit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Gap between X axis labels, in bars (default 5 for years, 1 for decades)",
+                        "name": "x_unit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "png (default), svg or jpeg; overrides the Accept header",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Width in pixels, 200-4096 (default 1280)",
+                        "name": "width",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Height in pixels, 200-4096 (default 720)",
+                        "name": "height",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "light (default), dark, vivid-light, vivid-dark, nature-light, nature-dark, grafana, ant, retro, ocean, slate, gray, winter, spring, summer or fall",
+                        "name": "theme",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Axis text size, 6-48; titles are drawn larger",
+                        "name": "font_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Count remasters and compilations towards the year the song was first released",
+                        "name": "resolve_original_year",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)",
+                        "name": "dedupe",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
                     },
                     "400": {
-                        "description": "Invalid year or JSON body",
+                        "description": "Invalid parameter",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Playlist not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch tracks or render chart",
                         "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/graphs/playlistTracksByYear/data": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The labels, year or decade buckets and track counts, or percentages, /graphs/playlistTracksByYear draws, with a series per playlist.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "graphs"
+                ],
+                "summary": "Data behind the playlist tracks by release year chart",
+                "parameters": [
+                    {
+                        "type": "array",
+                        "items": {
                             "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Playlists to count, up to 10; repeat the param or separate with commas",
+                        "name": "playlist_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "year (default) or decade",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Give each playlist's counts as a percentage of its tracks",
+                        "name": "percent",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Count remasters and compilations towards the year the song was first released",
+                        "name": "resolve_original_year",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)",
+                        "name": "dedupe",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.BarChartData"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameter",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Playlist not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch tracks",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
+                    }
+                }
+            }
+        },
+        "/graphs/topArtists": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Grouped bar or slope chart of where the user's top artists rank in the long, medium and short term. Artists in the top N of any range are shown. Those that climbed or dropped at least 5 places from long to short term have the change after their name, and on the slope chart are drawn green or red. Rendered charts are cached for 10 minutes per user, parameters and format.",
+                "produces": [
+                    "image/png",
+                    "image/svg+xml",
+                    "image/jpeg"
+                ],
+                "tags": [
+                    "graphs"
+                ],
+                "summary": "Chart of the user's top artists over time",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Artists shown, 1-50 (default 10)",
+                        "name": "count",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "bars (default) or slope",
+                        "name": "chart",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "png (default), svg or jpeg; overrides the Accept header",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Width in pixels, 200-4096 (default 1280)",
+                        "name": "width",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Height in pixels, 200-4096 (default 720)",
+                        "name": "height",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "light (default), dark, vivid-light, vivid-dark, nature-light, nature-dark, grafana, ant, retro, ocean, slate, gray, winter, spring, summer or fall",
+                        "name": "theme",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Label text size, 6-48; titles are drawn larger",
+                        "name": "font_size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameter",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch top artists or render chart",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/graphs/topArtists/data": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The artists /graphs/topArtists draws, best ranked first, with their rank in each range (null outside the top 50), places climbed from long to short term and whether that makes them rising, falling or steady.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "graphs"
+                ],
+                "summary": "Data behind the top artists chart",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Artists listed, 1-50 (default 10)",
+                        "name": "count",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.TopArtistsData"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameter",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch top artists",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/graphs/topTrackHeatmap": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Counts the user's top tracks for the time range in release year and Spotify popularity buckets. Rendered charts are cached for 10 minutes per user, parameters and format.",
+                "produces": [
+                    "image/png",
+                    "image/svg+xml",
+                    "image/jpeg"
+                ],
+                "tags": [
+                    "graphs"
+                ],
+                "summary": "Heatmap of the user's top tracks by release year and popularity",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "short_term, medium_term or long_term",
+                        "name": "time_range",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Years per column, 1-50 (default 3)",
+                        "name": "year_bucket",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Popularity points per row, 1-100 (default 10)",
+                        "name": "popularity_bucket",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "png (default), svg or jpeg; overrides the Accept header",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Width in pixels, 200-4096 (default 1280)",
+                        "name": "width",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Height in pixels, 200-4096 (default 720)",
+                        "name": "height",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "light (default), dark, vivid-light, vivid-dark, nature-light, nature-dark, grafana, ant, retro, ocean, slate, gray, winter, spring, summer or fall",
+                        "name": "theme",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Axis text size, 6-48; titles are drawn larger",
+                        "name": "font_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Count remasters and compilations towards the year the song was first released",
+                        "name": "resolve_original_year",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)",
+                        "name": "dedupe",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameter",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch tracks or render chart",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/graphs/topTrackHeatmap/data": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The year and popularity buckets and the track count in each cell that /graphs/topTrackHeatmap draws. Rows run from the least popular bucket up.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "graphs"
+                ],
+                "summary": "Data behind the top tracks heatmap",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "short_term, medium_term or long_term",
+                        "name": "time_range",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Years per column, 1-50 (default 3)",
+                        "name": "year_bucket",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Popularity points per row, 1-100 (default 10)",
+                        "name": "popularity_bucket",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Count remasters and compilations towards the year the song was first released",
+                        "name": "resolve_original_year",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)",
+                        "name": "dedupe",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.HeatmapData"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameter",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch tracks",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/graphs/topTracksByYear": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Bar chart of how many of the user's top tracks for the time range were released in each year or decade. Rendered charts are cached for 10 minutes per user, parameters and format.",
+                "produces": [
+                    "image/png",
+                    "image/svg+xml",
+                    "image/jpeg"
+                ],
+                "tags": [
+                    "graphs"
+                ],
+                "summary": "Chart of the user's top tracks by release year",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "short_term, medium_term or long_term",
+                        "name": "time_range",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "year (default) or decade",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Show the bars as a percentage of the tracks",
+                        "name": "percent",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Gap between Y axis labels, in tracks or percent",
+                        "name": "y_unit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Gap between X axis labels, in bars (default 5 for years, 1 for decades)",
+                        "name": "x_unit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "png (default), svg or jpeg; overrides the Accept header",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Width in pixels, 200-4096 (default 1280)",
+                        "name": "width",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Height in pixels, 200-4096 (default 720)",
+                        "name": "height",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "light (default), dark, vivid-light, vivid-dark, nature-light, nature-dark, grafana, ant, retro, ocean, slate, gray, winter, spring, summer or fall",
+                        "name": "theme",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Axis text size, 6-48; titles are drawn larger",
+                        "name": "font_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Count remasters and compilations towards the year the song was first released",
+                        "name": "resolve_original_year",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)",
+                        "name": "dedupe",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameter",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch tracks or render chart",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/graphs/topTracksByYear/data": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The labels, year or decade buckets and track counts /graphs/topTracksByYear draws.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "graphs"
+                ],
+                "summary": "Data behind the top tracks by release year chart",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "short_term, medium_term or long_term",
+                        "name": "time_range",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "year (default) or decade",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Give the counts as a percentage of the tracks",
+                        "name": "percent",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Count remasters and compilations towards the year the song was first released",
+                        "name": "resolve_original_year",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)",
+                        "name": "dedupe",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.BarChartData"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameter",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch tracks",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/me": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns information about the currently authenticated Spotify user",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "Get current Spotify user",
+                "responses": {
+                    "200": {
+                        "description": "Spotify user info",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "401": {
+                        "description": "Spotify client missing in context",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch Spotify user",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/ping": {
+            "get": {
+                "description": "Returns service health and Go runtime memory statistics",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Ping / health check",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handlers.PingResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/plans/{planId}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns a playlist plan previously created by a dry run",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "plans"
+                ],
+                "summary": "Get a stored playlist plan",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Plan ID",
+                        "name": "planId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.PlaylistPlan"
+                        }
+                    },
+                    "401": {
+                        "description": "Failed to identify the Spotify user",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Plan belongs to a different user",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Plan not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/plans/{planId}/apply": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Makes exactly the playlist changes recorded in a plan from a dry run. Fails without changing anything if a playlist to be updated was edited after the plan was made.\nProgress is saved to the plan after every step, so if applying fails part way, applying it again carries on from the step that failed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "plans"
+                ],
+                "summary": "Apply a stored playlist plan",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Plan ID",
+                        "name": "planId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.PlaylistPlan"
+                        }
+                    },
+                    "401": {
+                        "description": "Failed to identify the Spotify user",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Plan belongs to a different user",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Plan not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Plan already applied or playlist changed since the plan was made",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to apply plan",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/suggestions/defaults": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the suggestion options used when a suggestions request leaves them out",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "suggestions"
+                ],
+                "summary": "Get the user's default suggestion options",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.SuggestionOptions"
+                        }
+                    },
+                    "401": {
+                        "description": "Failed to identify the Spotify user",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to load suggestion defaults",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Replaces the suggestion options used when a suggestions request leaves them out",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "suggestions"
+                ],
+                "summary": "Set the user's default suggestion options",
+                "parameters": [
+                    {
+                        "description": "Default suggestion options",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.SuggestionOptions"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.SuggestionOptions"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid JSON body or suggestion options",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Failed to identify the Spotify user",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to save suggestion defaults",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/suggestions/feedback": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Likes or dislikes suggested tracks, or blocks their main artist, to tune future suggestions. Disliked tracks are never suggested again, blocked artists are dropped from the seeds, and every 2 liked tracks by an artist give it one more track per run, up to 5 more.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "suggestions"
+                ],
+                "summary": "Give feedback on suggested tracks",
+                "parameters": [
+                    {
+                        "description": "Feedback",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.SuggestionFeedbackRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/services.SuggestionFeedback"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid JSON body or feedback, or an unknown track ID",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Failed to identify the Spotify user",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to save suggestion feedback",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/year/{year}/analysis": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Combines tracks from playlists and liked songs, fetches suggestions, optionally saves JSON, and optionally creates Spotify playlists.\nGenerated playlists are configured with \"playlists\": name/description templates, visibility, and which buckets (liked, onPlaylists, suggestions) each one contains. Tracks that can't be played in the user's country are left out of generated playlists, and flagged with playable=false in the results.\nWith coverArt.enabled=true each playlist gets a generated cover showing the year and the library's tracks by year.\n\"playlistSelection\" picks which playlists count as on_playlists (by ID, name pattern, owner, collaborative flag and track count); the response lists the playlists used.\n\"suggestions\" configures how suggestions are built (seed artist strategies and genre filters); suggestions are ordered by their suggestion score and carry the reasons they were picked, falling back to the user's stored defaults; suggestion_report explains what was dropped.\n\"dedupe\" keeps one version of songs that appear more than once (album, single, remaster, live), in each bucket and in each generated playlist: the most popular, the earliest release or the album version. By default (\"none\") they're all kept.\nWith dryRun=true the playlist changes are returned as a plan and stored instead of applied; apply it later with POST /plans/{planId}/apply.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "year"
+                ],
+                "summary": "Perform full year analysis",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Year to analyze",
+                        "name": "year",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only tracks released in this quarter, e.g. 3 or Q3",
+                        "name": "quarter",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Request body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/yearHandlers.YearAnalysisRequestBody"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/yearHandlers.YearAnalysisResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid year, JSON body, playlist template, cover art, ranking options, playlist selection, suggestion options or fields",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Failed to identify the Spotify user",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch tracks or create playlists",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/year/{year}/likedSongs": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns a list of liked tracks filtered by year. Ordered by the ranking strategy in the body (popularity by default). Optionally saves the results if SaveObject=true.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "year"
+                ],
+                "summary": "Get liked songs from a specific year",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Year to filter by",
+                        "name": "year",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only tracks released in this quarter, e.g. 3 or Q3",
+                        "name": "quarter",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Request body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/yearHandlers.LikedSongsBody"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/yearHandlers.PlaylistTracksResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid year, JSON body, ranking options, playlist selection or fields",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch tracks",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/year/{year}/month/{month}/analysis": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Same as the year analysis, narrowed to tracks released in the given month. Tracks whose release date is only known to the year are excluded.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "year"
+                ],
+                "summary": "Perform full analysis for a single month",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Year the tracks were released in",
+                        "name": "year",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Month the tracks were released in (1-12)",
+                        "name": "month",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/yearHandlers.YearAnalysisRequestBody"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/yearHandlers.YearAnalysisResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid year, month, JSON body, playlist template, cover art, ranking options, playlist selection, suggestion options or fields",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Failed to identify the Spotify user",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch tracks or create playlists",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/year/{year}/month/{month}/likedSongs": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns a list of liked tracks released in the given month. Tracks whose release date is only known to the year are excluded.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "year"
+                ],
+                "summary": "Get liked songs from a specific month",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Year the tracks were released in",
+                        "name": "year",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Month the tracks were released in (1-12)",
+                        "name": "month",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/yearHandlers.LikedSongsBody"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/yearHandlers.PlaylistTracksResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid year, month, JSON body, ranking options, playlist selection or fields",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch tracks",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/year/{year}/month/{month}/songsFromPlaylists": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns tracks released in the given month from the playlists picked by playlistSelection, along with the playlists that were used. Tracks whose release date is only known to the year are excluded.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "year"
+                ],
+                "summary": "Get tracks from user playlists filtered by month",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Year the tracks were released in",
+                        "name": "year",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Month the tracks were released in (1-12)",
+                        "name": "month",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/yearHandlers.SongsOnPlaylistsFromYearRequestBody"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/yearHandlers.PlaylistTracksResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid year, month, JSON body, ranking options, playlist selection or fields",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch tracks",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/year/{year}/month/{month}/suggestions": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns suggested tracks released in the given month, and a report of how they were picked. Tracks whose release date is only known to the year are excluded.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "year"
+                ],
+                "summary": "Get suggested tracks from a specific month",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Year the tracks were released in",
+                        "name": "year",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Month the tracks were released in (1-12)",
+                        "name": "month",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Request body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/yearHandlers.SuggestionsFromYearRequestBody"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/yearHandlers.SuggestionsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid year, month, JSON body, ranking options, playlist selection, suggestion options or fields",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Failed to identify the Spotify user",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch tracks",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/year/{year}/songsFromPlaylists": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns tracks from the playlists picked by playlistSelection (all playlists by default), along with the playlists that were used.\nPlaylists can be picked by ID, name pattern, owner, collaborative flag and track count. Tracks are ordered by the ranking strategy in the body (popularity by default). Optionally saves results if SaveObject=true.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "year"
+                ],
+                "summary": "Get tracks from user playlists filtered by year",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Year to filter by",
+                        "name": "year",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only tracks released in this quarter, e.g. 3 or Q3",
+                        "name": "quarter",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Request body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/yearHandlers.SongsOnPlaylistsFromYearRequestBody"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/yearHandlers.PlaylistTracksResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid year, JSON body, ranking options, playlist selection or fields",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch tracks",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/year/{year}/suggestions": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns suggested tracks for the given year and a report of how they were picked. Seed artists come from the strategies in \"suggestions.seeds\" (the user's top artists by default), each with its own weight, capped at maxSeedArtists. Optionally saves results if SaveObject=true.\nEach track has a score combining seed artist affinity, popularity and album diversity, and the reasons it was suggested; tracks are ordered by that score unless a ranking strategy is given.\nTracks already in the user's liked songs are never suggested. Neither are tracks that can't be played in the user's country. Every suggestion is remembered, and \"suggestions.previouslySuggested\" picks whether repeats are ranked lower (default), skipped or included.\n\"suggestions.genres\" drops seed artists by genre; the report lists each dropped artist and the rule that dropped it. Search hits by artists other than the seed artist are dropped and counted in the report.\nUp to \"suggestions.tracksPerArtist\" tracks (5) are picked per artist and \"suggestions.tracksPerAlbum\" (3) per album, from the first \"suggestions.searchLimit\" search results (50). \"suggestions.deepCuts\" looks further (200 results) and prefers less popular tracks from albums already in the library. \"suggestions.maxSuggestions\" caps the whole list.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "year"
+                ],
+                "summary": "Get suggested tracks from a specific year",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Year to get suggestions for",
+                        "name": "year",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only tracks released in this quarter, e.g. 3 or Q3",
+                        "name": "quarter",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Request body",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/yearHandlers.SuggestionsFromYearRequestBody"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/yearHandlers.SuggestionsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid year, JSON body, ranking options, playlist selection, suggestion options or fields",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Failed to identify the Spotify user",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to fetch tracks",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "handlers.MemStatsResponse": {
+            "description": "Go runtime memory stats",
+            "type": "object",
+            "properties": {
+                "alloc_bytes": {
+                    "type": "integer"
+                },
+                "heap_alloc_bytes": {
+                    "type": "integer"
+                },
+                "heap_sys_bytes": {
+                    "type": "integer"
+                },
+                "num_gc": {
+                    "type": "integer"
+                },
+                "sys_bytes": {
+                    "type": "integer"
+                },
+                "total_alloc_bytes": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handlers.PingResponse": {
+            "description": "Response for ping endpoint",
+            "type": "object",
+            "properties": {
+                "mem_stats": {
+                    "$ref": "#/definitions/handlers.MemStatsResponse"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.ArtistYearCount": {
+            "description": "An artist and how many of their tracks from each compared year are in the library",
+            "type": "object",
+            "properties": {
+                "artist_id": {
+                    "type": "string"
+                },
+                "count_a": {
+                    "type": "integer"
+                },
+                "count_b": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.BarChartData": {
+            "description": "The numbers a bar chart is drawn from. Each series has one value per label.",
+            "type": "object",
+            "properties": {
+                "buckets": {
+                    "description": "what each label covers, for axes of ranges",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ChartBucket"
+                    }
+                },
+                "labels": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "series": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ChartSeries"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                },
+                "x_title": {
+                    "type": "string"
+                },
+                "y_title": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.ChartBucket": {
+            "description": "The range of values counted under one label, both ends included",
+            "type": "object",
+            "properties": {
+                "end": {
+                    "type": "integer",
+                    "example": 1999
+                },
+                "label": {
+                    "type": "string",
+                    "example": "1990s"
+                },
+                "start": {
+                    "type": "integer",
+                    "example": 1990
+                }
+            }
+        },
+        "services.ChartSeries": {
+            "description": "One named series of a chart, with a value for each label",
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "Tracks"
+                },
+                "values": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                }
+            }
+        },
+        "services.CoverArtOptions": {
+            "description": "Cover image rendered for generated playlists. Colours accept hex (#1db954), rgb(...) or common names.",
+            "type": "object",
+            "properties": {
+                "backgroundColor": {
+                    "type": "string",
+                    "example": "#191414"
+                },
+                "barColor": {
+                    "type": "string",
+                    "example": "#535353"
+                },
+                "enabled": {
+                    "type": "boolean"
+                },
+                "highlightColor": {
+                    "type": "string",
+                    "example": "#1db954"
+                },
+                "template": {
+                    "description": "\"chart\" (year and tracks-by-year bars) or \"minimal\" (year only)",
+                    "type": "string",
+                    "example": "chart"
+                },
+                "textColor": {
+                    "type": "string",
+                    "example": "#ffffff"
+                }
+            }
+        },
+        "services.DroppedArtist": {
+            "description": "A seed artist that was left out of suggestions, and the rule that dropped it",
+            "type": "object",
+            "properties": {
+                "artist_id": {
+                    "type": "string"
+                },
+                "genre": {
+                    "description": "the artist's genre that matched, for exclude rules",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "rule": {
+                    "description": "exclude:\u003cgenre\u003e, include or blocked",
+                    "type": "string",
+                    "example": "exclude:classical"
+                }
+            }
+        },
+        "services.FeedbackArtist": {
+            "description": "An artist the user never wants suggested",
+            "type": "object",
+            "properties": {
+                "at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.FeedbackTrack": {
+            "description": "A track the user gave feedback on",
+            "type": "object",
+            "properties": {
+                "artist_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.GenreData": {
+            "description": "How the artists or tracks in a library split across genres. Genres past the top N are summed into a final \"other\" entry.",
+            "type": "object",
+            "properties": {
+                "labels": {
+                    "description": "genres, largest first, with \"other\" last",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "no_genre": {
+                    "description": "artists or tracks Spotify has no genres for, left out",
+                    "type": "integer"
+                },
+                "other_genres": {
+                    "description": "genres folded into \"other\"",
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "values": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "weight": {
+                    "description": "what each genre's value counts: artists or tracks",
+                    "type": "string",
+                    "example": "artists"
+                }
+            }
+        },
+        "services.GenreFilter": {
+            "description": "Which seed artists to keep by genre. Artists with a genre matching an exclude entry are dropped; when include is set, only artists with a genre matching an include entry are kept. Matching ignores case.",
+            "type": "object",
+            "properties": {
+                "exclude": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "classical"
+                    ]
+                },
+                "include": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "match": {
+                    "description": "substring (default) or exact",
+                    "type": "string",
+                    "example": "substring"
+                }
+            }
+        },
+        "services.GrowthSeries": {
+            "description": "How one of the liked songs or a playlist grew, with a value for each month",
+            "type": "object",
+            "properties": {
+                "added": {
+                    "description": "tracks added that month",
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Liked Songs"
+                },
+                "playlist_id": {
+                    "type": "string"
+                },
+                "total": {
+                    "description": "tracks there by the end of the month",
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "undated": {
+                    "description": "tracks with no added date, left out",
+                    "type": "integer"
+                }
+            }
+        },
+        "services.HeatmapData": {
+            "description": "The numbers a heatmap is drawn from. values has a row for each y bucket, lowest first, and a column for each x bucket.",
+            "type": "object",
+            "properties": {
+                "title": {
+                    "type": "string"
+                },
+                "values": {
+                    "type": "array",
+                    "items": {
+                        "type": "array",
+                        "items": {
+                            "type": "number",
+                            "format": "float64"
+                        }
+                    }
+                },
+                "x_buckets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ChartBucket"
+                    }
+                },
+                "x_labels": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "x_title": {
+                    "type": "string"
+                },
+                "y_buckets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ChartBucket"
+                    }
+                },
+                "y_labels": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "y_title": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.LibraryGrowthData": {
+            "description": "How the user's liked songs and playlists grew over time. Only tracks still there are counted, so removed tracks don't show.",
+            "type": "object",
+            "properties": {
+                "months": {
+                    "description": "YYYY-MM, from the first month anything was added to the last",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "2019-03"
+                    ]
+                },
+                "series": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.GrowthSeries"
+                    }
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.PlaylistChange": {
+            "description": "A single playlist to create or update as part of a PlaylistPlan",
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "\"create\" or \"update\"",
+                    "type": "string"
+                },
+                "add": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "add_batches": {
+                    "type": "integer"
+                },
+                "applied": {
+                    "type": "boolean"
+                },
+                "collaborative": {
+                    "description": "updates keep the playlist's current setting",
+                    "type": "boolean"
+                },
+                "cover_image": {
+                    "description": "JPEG",
+                    "type": "string",
+                    "format": "base64"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "playlist_id": {
+                    "type": "string"
+                },
+                "progress": {
+                    "$ref": "#/definitions/services.PlaylistProgress"
+                },
+                "public": {
+                    "type": "boolean"
+                },
+                "remove": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "remove_batches": {
+                    "type": "integer"
+                },
+                "reorder": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.PlaylistReorder"
+                    }
+                },
+                "snapshot_id": {
+                    "type": "string"
+                },
+                "track_ids": {
+                    "description": "final track order once applied",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "services.PlaylistPlan": {
+            "description": "Every change that would be made to the user's Spotify playlists. Returned by dry runs and can be applied later by ID.",
+            "type": "object",
+            "properties": {
+                "applied_at": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "plan_id": {
+                    "type": "string"
+                },
+                "playlists": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.PlaylistChange"
+                    }
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.PlaylistProgress": {
+            "description": "How much of a PlaylistChange has been made, so an apply that fails part way is retried from where it stopped",
+            "type": "object",
+            "properties": {
+                "add_batches": {
+                    "type": "integer"
+                },
+                "cover": {
+                    "type": "boolean"
+                },
+                "details": {
+                    "description": "playlist created, or renamed for an update",
+                    "type": "boolean"
+                },
+                "moves": {
+                    "type": "integer"
+                },
+                "remove_batches": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.PlaylistReorder": {
+            "description": "Moves one track, applied in order after tracks have been removed and added",
+            "type": "object",
+            "properties": {
+                "from": {
+                    "type": "integer"
+                },
+                "to": {
+                    "type": "integer"
+                },
+                "track_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.PlaylistSelection": {
+            "description": "Which of the user's playlists to use. Every rule that is set must pass; an empty selection uses every playlist. Name patterns are Go regular expressions (prefix with (?i) to ignore case). Owner is \"me\", \"others\" or a Spotify user ID.",
+            "type": "object",
+            "properties": {
+                "collaborative": {
+                    "type": "boolean"
+                },
+                "excludeIds": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "excludeNamePatterns": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "excludeNameSubstrings": {
+                    "description": "case-insensitive",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "includeIds": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "includeNamePatterns": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "(?i)^20[0-9]{2}"
+                    ]
+                },
+                "maxTracks": {
+                    "type": "integer"
+                },
+                "minTracks": {
+                    "type": "integer"
+                },
+                "owner": {
+                    "type": "string",
+                    "example": "me"
+                }
+            }
+        },
+        "services.PlaylistSummary": {
+            "description": "A playlist that was used to build a response",
+            "type": "object",
+            "properties": {
+                "collaborative": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "owner_id": {
+                    "type": "string"
+                },
+                "track_count": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.PlaylistTemplate": {
+            "description": "How to build one generated playlist. Name and description can use {year}, {period}, {user}, {trackCount} and {date}. {period} is the year, or the month (\"2019-07\") or quarter (\"2019 Q3\") when the analysis is narrowed to one.",
+            "type": "object",
+            "properties": {
+                "buckets": {
+                    "description": "any of liked, onPlaylists, suggestions",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "onPlaylists",
+                        "liked"
+                    ]
+                },
+                "collaborative": {
+                    "type": "boolean"
+                },
+                "descriptionTemplate": {
+                    "type": "string",
+                    "example": "Generated playlist of favourites for {year}"
+                },
+                "nameTemplate": {
+                    "type": "string",
+                    "example": "{year} - favourites"
+                },
+                "public": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "services.RankingOptions": {
+            "description": "How to order tracks. Strategy is one of popularity (default), playlistCount, earliestAdded, topTracks or weighted. Weights apply to the weighted strategy and are keyed by the other strategy names; all are weighted equally if omitted.",
+            "type": "object",
+            "properties": {
+                "strategy": {
+                    "type": "string",
+                    "example": "weighted"
+                },
+                "weights": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "number",
+                        "format": "float64"
+                    }
+                }
+            }
+        },
+        "services.SeedArtist": {
+            "description": "An artist suggestions are searched for, and why it was picked",
+            "type": "object",
+            "properties": {
+                "artist_id": {
+                    "type": "string"
+                },
+                "followed": {
+                    "type": "boolean"
+                },
+                "library_tracks": {
+                    "description": "liked or playlist tracks from the target year",
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "playlist_tracks": {
+                    "description": "tracks on the seed playlists",
+                    "type": "integer"
+                },
+                "score": {
+                    "type": "number"
+                },
+                "sources": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "top_ranks": {
+                    "description": "time range -\u003e rank among the user's top artists",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "services.SeedStrategy": {
+            "description": "Where to find seed artists for suggestions. topArtists uses the user's top 25 artists in each time range; library uses artists on liked songs or playlists released in the target year; playlist uses the artists on one playlist; followed uses the artists the user follows.",
+            "type": "object",
+            "properties": {
+                "playlistId": {
+                    "description": "for the playlist source",
+                    "type": "string"
+                },
+                "source": {
+                    "description": "topArtists, library, playlist or followed",
+                    "type": "string",
+                    "example": "topArtists"
+                },
+                "weight": {
+                    "description": "how much this source counts towards an artist's seed score, 1 if left out",
+                    "type": "number",
+                    "example": 1
+                }
+            }
+        },
+        "services.SuggestionFeedback": {
+            "description": "Everything a user has said about their suggestions, by track or artist ID",
+            "type": "object",
+            "properties": {
+                "blocked_artists": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/services.FeedbackArtist"
+                    }
+                },
+                "disliked": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/services.FeedbackTrack"
+                    }
+                },
+                "liked": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/services.FeedbackTrack"
+                    }
+                }
+            }
+        },
+        "services.SuggestionFeedbackRequest": {
+            "description": "Feedback on suggested tracks. like and dislike apply to the tracks; neverArtist blocks each track's main artist from future suggestions.",
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "like, dislike or neverArtist",
+                    "type": "string",
+                    "example": "like"
+                },
+                "trackIds": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
                     },
-                    "500": {
-                        "description": "Failed to fetch tracks",
-                        "schema": {
-                            "type": "string"
-                        }
+                    "example": [
+                        "4uLU6hMCjMI75M1A2tKUQC"
+                    ]
+                }
+            }
+        },
+        "services.SuggestionOptions": {
+            "description": "Options for building suggestions. Anything left out falls back to the user's stored defaults.",
+            "type": "object",
+            "properties": {
+                "deepCuts": {
+                    "description": "Prefer less popular tracks from albums the user already likes over the hits",
+                    "type": "boolean"
+                },
+                "genres": {
+                    "$ref": "#/definitions/services.GenreFilter"
+                },
+                "maxSeedArtists": {
+                    "type": "integer",
+                    "example": 75
+                },
+                "maxSuggestions": {
+                    "description": "Most suggestions to return in total, no limit if left out",
+                    "type": "integer",
+                    "example": 100
+                },
+                "previouslySuggested": {
+                    "description": "What to do with tracks suggested on an earlier run: downrank (default), skip or include",
+                    "type": "string",
+                    "example": "downrank"
+                },
+                "searchLimit": {
+                    "description": "Search results looked at per seed artist, 50 by default or 200 for deep cuts, at most 1000",
+                    "type": "integer",
+                    "example": 50
+                },
+                "seeds": {
+                    "description": "Where seed artists come from, defaulting to the user's top artists",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.SeedStrategy"
                     }
+                },
+                "tracksPerAlbum": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "tracksPerArtist": {
+                    "description": "Tracks picked per seed artist (5 by default) and per album (3 by default)",
+                    "type": "integer",
+                    "example": 5
                 }
             }
-        }
-    },
-    "definitions": {
-        "handlers.MemStatsResponse": {
-            "description": "Go runtime memory stats",
+        },
+        "services.SuggestionReport": {
+            "description": "How suggestions were put together",
             "type": "object",
             "properties": {
-                "alloc_bytes": {
+                "downranked_previously_suggested": {
                     "type": "integer"
                 },
-                "heap_alloc_bytes": {
+                "dropped_artists": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.DroppedArtist"
+                    }
+                },
+                "max_suggestions": {
                     "type": "integer"
                 },
-                "heap_sys_bytes": {
+                "over_max_suggestions": {
+                    "description": "left out by maxSuggestions",
                     "type": "integer"
                 },
-                "num_gc": {
+                "rejected_search_results": {
+                    "description": "search hits by other artists",
                     "type": "integer"
                 },
-                "sys_bytes": {
+                "seeds": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.SeedArtist"
+                    }
+                },
+                "skipped_disliked": {
                     "type": "integer"
                 },
-                "total_alloc_bytes": {
+                "skipped_other_versions": {
+                    "description": "other versions of a song already in the library or results",
+                    "type": "integer"
+                },
+                "skipped_previously_suggested": {
+                    "type": "integer"
+                },
+                "skipped_saved": {
+                    "description": "already in the user's liked songs",
+                    "type": "integer"
+                },
+                "skipped_unplayable": {
+                    "description": "not playable in the user's country",
                     "type": "integer"
                 }
             }
         },
-        "handlers.PingResponse": {
-            "description": "Response for ping endpoint",
+        "services.TopArtistRank": {
+            "description": "An artist's rank among the user's top artists in each time range, null when outside the top 50",
             "type": "object",
             "properties": {
-                "mem_stats": {
-                    "$ref": "#/definitions/handlers.MemStatsResponse"
+                "artist_id": {
+                    "type": "string"
                 },
-                "status": {
+                "change": {
+                    "description": "places climbed from long to short term, counting unranked as 51st",
+                    "type": "integer"
+                },
+                "long_term": {
+                    "type": "integer"
+                },
+                "medium_term": {
+                    "type": "integer"
+                },
+                "name": {
                     "type": "string"
                 },
-                "time": {
+                "short_term": {
+                    "type": "integer"
+                },
+                "trend": {
+                    "description": "rising or falling when change is at least 5 places, otherwise steady",
+                    "type": "string",
+                    "example": "rising"
+                }
+            }
+        },
+        "services.TopArtistsData": {
+            "description": "The user's top artists across the time ranges. Artists come from the top N of any range, best ranked first.",
+            "type": "object",
+            "properties": {
+                "artists": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.TopArtistRank"
+                    }
+                },
+                "ranges": {
+                    "description": "the order ranks are drawn in",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "long_term",
+                        "medium_term",
+                        "short_term"
+                    ]
+                },
+                "title": {
                     "type": "string"
                 }
             }
         },
         "services.TrackInfo": {
-            "description": "Short track info returned by year endpoints",
+            "description": "Track info returned by year endpoints. The fields after popularity are left out when the compact form is requested.",
             "type": "object",
             "properties": {
+                "album_id": {
+                    "type": "string"
+                },
                 "album_name": {
                     "type": "string"
                 },
+                "album_type": {
+                    "type": "string"
+                },
+                "artist_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
                 "artists": {
                     "type": "array",
                     "items": {
                         "type": "string"
                     }
                 },
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "explicit": {
+                    "type": "boolean"
+                },
+                "external_url": {
+                    "type": "string"
+                },
+                "isrc": {
+                    "type": "string"
+                },
+                "linked_from_id": {
+                    "description": "The track asked for, when Spotify swapped in a version playable in the user's country",
+                    "type": "string"
+                },
+                "playable": {
+                    "description": "False when the track can't be played in the user's country",
+                    "type": "boolean"
+                },
                 "popularity": {
                     "type": "integer"
                 },
+                "provenance": {
+                    "$ref": "#/definitions/services.TrackProvenance"
+                },
+                "reasons": {
+                    "description": "Why a suggested track was suggested",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
                 "release_date": {
                     "type": "string"
                 },
+                "score": {
+                    "description": "Score and its parts from the ranking strategy used to order the results",
+                    "type": "number"
+                },
+                "score_components": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "number",
+                        "format": "float64"
+                    }
+                },
                 "track_id": {
                     "type": "string"
                 },
                 "track_name": {
                     "type": "string"
+                },
+                "track_number": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.TrackProvenance": {
+            "description": "Where a track was found while building the response",
+            "type": "object",
+            "properties": {
+                "liked": {
+                    "type": "boolean"
+                },
+                "playlist_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "suggested": {
+                    "type": "boolean"
+                },
+                "top_tracks": {
+                    "description": "time ranges the track is a top track in",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "short_term"
+                    ]
+                }
+            }
+        },
+        "services.YearComparison": {
+            "description": "Two release years of the library side by side",
+            "type": "object",
+            "properties": {
+                "a": {
+                    "$ref": "#/definitions/services.YearSummary"
+                },
+                "b": {
+                    "$ref": "#/definitions/services.YearSummary"
+                },
+                "only_in_a": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ArtistYearCount"
+                    }
+                },
+                "only_in_b": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ArtistYearCount"
+                    }
+                },
+                "playlists_used": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.PlaylistSummary"
+                    }
+                },
+                "popularity_change": {
+                    "description": "B's average popularity minus A's",
+                    "type": "number"
+                },
+                "shared_artists": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ArtistYearCount"
+                    }
+                }
+            }
+        },
+        "services.YearSummary": {
+            "description": "How much of the library was released in one year",
+            "type": "object",
+            "properties": {
+                "average_popularity": {
+                    "type": "number"
+                },
+                "liked_count": {
+                    "type": "integer"
+                },
+                "on_playlists_count": {
+                    "type": "integer"
+                },
+                "total_count": {
+                    "description": "liked or on a playlist, counted once",
+                    "type": "integer"
+                },
+                "year": {
+                    "type": "integer"
                 }
             }
         },
@@ -386,21 +3510,85 @@ const docTemplate = `{
             "description": "Body for fetching liked songs",
             "type": "object",
             "properties": {
+                "dedupe": {
+                    "description": "Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)",
+                    "type": "string",
+                    "example": "mostPopular"
+                },
+                "fields": {
+                    "description": "\"compact\" (default) for just the ID, names, release date, popularity and score, or \"full\" for every field and where the track came from",
+                    "type": "string",
+                    "example": "full"
+                },
+                "playlistSelection": {
+                    "description": "The playlists the playlistCount and earliestAdded rankings look at; all of them by default",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/services.PlaylistSelection"
+                        }
+                    ]
+                },
+                "ranking": {
+                    "$ref": "#/definitions/services.RankingOptions"
+                },
+                "resolveOriginalYear": {
+                    "description": "Count remasters and compilations towards the year the song was first released",
+                    "type": "boolean"
+                },
                 "saveObject": {
                     "type": "boolean"
                 }
             }
         },
+        "yearHandlers.PlaylistTracksResponse": {
+            "description": "Tracks, and the playlists picked by the playlist selection that were used to find or rank them",
+            "type": "object",
+            "properties": {
+                "playlists_used": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.PlaylistSummary"
+                    }
+                },
+                "tracks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.TrackInfo"
+                    }
+                }
+            }
+        },
         "yearHandlers.SongsOnPlaylistsFromYearRequestBody": {
             "description": "Request body for fetching tracks from playlists filtered by year",
             "type": "object",
             "properties": {
+                "dedupe": {
+                    "description": "Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)",
+                    "type": "string",
+                    "example": "mostPopular"
+                },
+                "fields": {
+                    "description": "\"compact\" (default) for just the ID, names, release date, popularity and score, or \"full\" for every field and where the track came from",
+                    "type": "string",
+                    "example": "full"
+                },
                 "ignoredPlaylistNameSubstrings": {
+                    "description": "Kept for older clients; the same as playlistSelection.excludeNameSubstrings",
                     "type": "array",
                     "items": {
                         "type": "string"
                     }
                 },
+                "playlistSelection": {
+                    "$ref": "#/definitions/services.PlaylistSelection"
+                },
+                "ranking": {
+                    "$ref": "#/definitions/services.RankingOptions"
+                },
+                "resolveOriginalYear": {
+                    "description": "Count remasters and compilations towards the year the song was first released",
+                    "type": "boolean"
+                },
                 "saveObject": {
                     "type": "boolean"
                 }
@@ -410,8 +3598,62 @@ const docTemplate = `{
             "description": "Body for fetching suggested tracks from a year",
             "type": "object",
             "properties": {
+                "dedupe": {
+                    "description": "Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)",
+                    "type": "string",
+                    "example": "mostPopular"
+                },
+                "fields": {
+                    "description": "\"compact\" (default) for just the ID, names, release date, popularity and score, or \"full\" for every field and where the track came from",
+                    "type": "string",
+                    "example": "full"
+                },
+                "playlistSelection": {
+                    "description": "The playlists used for library seeds, album familiarity and the playlistCount and earliestAdded rankings; all of them by default",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/services.PlaylistSelection"
+                        }
+                    ]
+                },
+                "ranking": {
+                    "$ref": "#/definitions/services.RankingOptions"
+                },
+                "resolveOriginalYear": {
+                    "description": "Count remasters and compilations towards the year the song was first released",
+                    "type": "boolean"
+                },
                 "saveObject": {
                     "type": "boolean"
+                },
+                "suggestions": {
+                    "description": "Falls back to the user's stored defaults (see /suggestions/defaults) for anything left out",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/services.SuggestionOptions"
+                        }
+                    ]
+                }
+            }
+        },
+        "yearHandlers.SuggestionsResponse": {
+            "description": "Suggested tracks, and how they were picked",
+            "type": "object",
+            "properties": {
+                "playlists_used": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.PlaylistSummary"
+                    }
+                },
+                "report": {
+                    "$ref": "#/definitions/services.SuggestionReport"
+                },
+                "tracks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.TrackInfo"
+                    }
                 }
             }
         },
@@ -419,7 +3661,24 @@ const docTemplate = `{
             "description": "Request body for performing a full year analysis (on playlists, liked songs, suggestions)",
             "type": "object",
             "properties": {
+                "coverArt": {
+                    "$ref": "#/definitions/services.CoverArtOptions"
+                },
+                "dedupe": {
+                    "description": "Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)",
+                    "type": "string",
+                    "example": "mostPopular"
+                },
+                "dryRun": {
+                    "type": "boolean"
+                },
+                "fields": {
+                    "description": "\"compact\" (default) for just the ID, names, release date, popularity and score, or \"full\" for every field and where the track came from",
+                    "type": "string",
+                    "example": "full"
+                },
                 "ignoredPlaylistNameSubstrings": {
+                    "description": "Kept for older clients; the same as playlistSelection.excludeNameSubstrings",
                     "type": "array",
                     "items": {
                         "type": "string"
@@ -428,8 +3687,36 @@ const docTemplate = `{
                 "makePlaylists": {
                     "type": "boolean"
                 },
+                "playlistSelection": {
+                    "$ref": "#/definitions/services.PlaylistSelection"
+                },
+                "playlists": {
+                    "description": "Playlists to generate when MakePlaylists is set. Defaults to \"{year} - favourites\" and \"{year} - suggestions\".",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.PlaylistTemplate"
+                    }
+                },
+                "ranking": {
+                    "$ref": "#/definitions/services.RankingOptions"
+                },
+                "replaceExisting": {
+                    "type": "boolean"
+                },
+                "resolveOriginalYear": {
+                    "description": "Count remasters and compilations towards the year the song was first released",
+                    "type": "boolean"
+                },
                 "saveObject": {
                     "type": "boolean"
+                },
+                "suggestions": {
+                    "description": "Falls back to the user's stored defaults (see /suggestions/defaults) for anything left out",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/services.SuggestionOptions"
+                        }
+                    ]
                 }
             }
         },
@@ -449,6 +3736,18 @@ const docTemplate = `{
                         "$ref": "#/definitions/services.TrackInfo"
                     }
                 },
+                "plan": {
+                    "$ref": "#/definitions/services.PlaylistPlan"
+                },
+                "playlists_used": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.PlaylistSummary"
+                    }
+                },
+                "suggestion_report": {
+                    "$ref": "#/definitions/services.SuggestionReport"
+                },
                 "suggestions": {
                     "type": "array",
                     "items": {
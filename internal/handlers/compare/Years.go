@@ -0,0 +1,225 @@
+package compareHandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	graphHandlers "github.com/CallumClarke65/spotify-analytics/internal/handlers/graphs"
+	"github.com/CallumClarke65/spotify-analytics/internal/services"
+	"github.com/CallumClarke65/spotify-analytics/internal/spotifyauth"
+	"go.uber.org/zap"
+)
+
+// listParam reads a query param that can be given more than once or comma separated
+func listParam(query url.Values, name string) []string {
+	var values []string
+	for _, value := range query[name] {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// optionalIntParam reads a whole number query param, nil when it's left out
+func optionalIntParam(query url.Values, name string) (*int, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a whole number", name)
+	}
+	return &value, nil
+}
+
+// playlistSelectionFromQuery reads the playlist selection rules from the query. Name
+// patterns can contain commas, so they can only be repeated, not comma separated.
+func playlistSelectionFromQuery(query url.Values) (services.PlaylistSelection, error) {
+	selection := services.PlaylistSelection{
+		IncludeIDs:            listParam(query, "include_playlist_id"),
+		ExcludeIDs:            listParam(query, "exclude_playlist_id"),
+		IncludeNamePatterns:   query["include_name_pattern"],
+		ExcludeNamePatterns:   query["exclude_name_pattern"],
+		ExcludeNameSubstrings: listParam(query, "exclude_name_substring"),
+		Owner:                 query.Get("owner"),
+	}
+
+	if raw := query.Get("collaborative"); raw != "" {
+		collaborative, err := strconv.ParseBool(raw)
+		if err != nil {
+			return services.PlaylistSelection{}, errors.New("collaborative must be true or false")
+		}
+		selection.Collaborative = &collaborative
+	}
+	minTracks, err := optionalIntParam(query, "min_tracks")
+	if err != nil {
+		return services.PlaylistSelection{}, err
+	}
+	maxTracks, err := optionalIntParam(query, "max_tracks")
+	if err != nil {
+		return services.PlaylistSelection{}, err
+	}
+	selection.MinTracks = minTracks
+	selection.MaxTracks = maxTracks
+
+	return selection, selection.Validate()
+}
+
+// compareYears reads the a and b query params and compares those years from a single
+// fetch of the user's library, using only the playlists picked by the selection params
+func compareYears(r *http.Request) (services.YearComparison, int, error) {
+	query := r.URL.Query()
+	a, err := strconv.Atoi(query.Get("a"))
+	if err != nil {
+		return services.YearComparison{}, http.StatusBadRequest, errors.New("invalid year a")
+	}
+	b, err := strconv.Atoi(query.Get("b"))
+	if err != nil {
+		return services.YearComparison{}, http.StatusBadRequest, errors.New("invalid year b")
+	}
+
+	selection, err := playlistSelectionFromQuery(query)
+	if err != nil {
+		return services.YearComparison{}, http.StatusBadRequest, fmt.Errorf("invalid playlist selection: %w", err)
+	}
+
+	client := spotifyauth.ClientFromContext(r.Context())
+	library, err := services.GetLibrary(r.Context(), client, selection)
+	if err != nil {
+		return services.YearComparison{}, http.StatusInternalServerError, errors.New("failed to fetch library")
+	}
+
+	if resolve, _ := strconv.ParseBool(query.Get("resolve_original_year")); resolve {
+		from := min(a, b)
+		ctx := services.WithOriginalReleaseBudget(r.Context())
+		library.Liked = services.ResolveOriginalReleaseDates(ctx, client, library.Liked, from)
+		library.OnPlaylists = services.ResolveOriginalReleaseDates(ctx, client, library.OnPlaylists, from)
+	}
+
+	return services.CompareYears(library, a, b), http.StatusOK, nil
+}
+
+// comparisonChartData is what the comparison chart is drawn from
+func comparisonChartData(r *http.Request, comparison services.YearComparison) services.BarChartData {
+	title := fmt.Sprintf("%s's Library - %d vs %d", spotifyauth.UserNameFromContext(r.Context()), comparison.A.Year, comparison.B.Year)
+	return services.AggregateYearComparison(comparison, title)
+}
+
+// GetYearComparisonHandler godoc
+// @Summary Compare two release years
+// @Description Counts the liked and on-playlist tracks released in each year, lists the artists found in both years and those unique to each, and the change in average popularity from a to b.
+// @Description Only the playlists picked by the selection params are counted, and those playlists are listed in playlists_used.
+// @Tags compare
+// @Produce json
+// @Param a query int true "First year"
+// @Param b query int true "Second year"
+// @Param resolve_original_year query bool false "Count remasters and compilations towards the year the song was first released"
+// @Param include_playlist_id query []string false "Only use these playlists" collectionFormat(multi)
+// @Param exclude_playlist_id query []string false "Never use these playlists" collectionFormat(multi)
+// @Param include_name_pattern query []string false "Only use playlists whose name matches one of these regular expressions" collectionFormat(multi)
+// @Param exclude_name_pattern query []string false "Skip playlists whose name matches one of these regular expressions" collectionFormat(multi)
+// @Param exclude_name_substring query []string false "Skip playlists whose name contains one of these, ignoring case" collectionFormat(multi)
+// @Param owner query string false "me, others or a Spotify user ID"
+// @Param collaborative query bool false "Only use collaborative (true) or non-collaborative (false) playlists"
+// @Param min_tracks query int false "Skip playlists with fewer tracks"
+// @Param max_tracks query int false "Skip playlists with more tracks"
+// @Success 200 {object} services.YearComparison
+// @Failure 400 {string} string "Invalid year or playlist selection"
+// @Failure 500 {string} string "Failed to fetch library"
+// @Security ApiKeyAuth
+// @Router /compare/years [get]
+func GetYearComparisonHandler(w http.ResponseWriter, r *http.Request) {
+	comparison, status, err := compareYears(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comparison)
+}
+
+// GetYearComparisonChartHandler godoc
+// @Summary Chart comparing two release years
+// @Description Side-by-side bars of each year's liked, on-playlist and total tracks, and artists. Rendered charts are cached for 10 minutes per user, parameters and format.
+// @Tags compare
+// @Produce image/png,image/svg+xml,image/jpeg
+// @Param a query int true "First year"
+// @Param b query int true "Second year"
+// @Param resolve_original_year query bool false "Count remasters and compilations towards the year the song was first released"
+// @Param include_playlist_id query []string false "Only use these playlists" collectionFormat(multi)
+// @Param exclude_playlist_id query []string false "Never use these playlists" collectionFormat(multi)
+// @Param include_name_pattern query []string false "Only use playlists whose name matches one of these regular expressions" collectionFormat(multi)
+// @Param exclude_name_pattern query []string false "Skip playlists whose name matches one of these regular expressions" collectionFormat(multi)
+// @Param exclude_name_substring query []string false "Skip playlists whose name contains one of these, ignoring case" collectionFormat(multi)
+// @Param owner query string false "me, others or a Spotify user ID"
+// @Param collaborative query bool false "Only use collaborative (true) or non-collaborative (false) playlists"
+// @Param min_tracks query int false "Skip playlists with fewer tracks"
+// @Param max_tracks query int false "Skip playlists with more tracks"
+// @Param format query string false "png (default), svg or jpeg; overrides the Accept header"
+// @Param width query int false "Width in pixels, 200-4096 (default 1280)"
+// @Param height query int false "Height in pixels, 200-4096 (default 720)"
+// @Param theme query string false "light (default), dark, vivid-light, vivid-dark, nature-light, nature-dark, grafana, ant, retro, ocean, slate, gray, winter, spring, summer or fall"
+// @Param font_size query number false "Axis text size, 6-48; titles are drawn larger"
+// @Success 200 {file} binary
+// @Failure 400 {string} string "Invalid year, playlist selection, format or chart option"
+// @Failure 500 {string} string "Failed to fetch library or render chart"
+// @Security ApiKeyAuth
+// @Router /compare/years/chart [get]
+func GetYearComparisonChartHandler(w http.ResponseWriter, r *http.Request) {
+	graphHandlers.ServeChart(w, r, func(chartOpts services.ChartOptions) ([]byte, int, error) {
+		comparison, status, err := compareYears(r)
+		if err != nil {
+			return nil, status, err
+		}
+
+		buf, err := services.RenderYearComparison(comparisonChartData(r, comparison), chartOpts)
+		if err != nil {
+			zap.L().Error("Failed to render chart to bytes", zap.Error(err))
+			return nil, http.StatusInternalServerError, errors.New("failed to render chart")
+		}
+		return buf, http.StatusOK, nil
+	})
+}
+
+// GetYearComparisonChartDataHandler godoc
+// @Summary Data behind the year comparison chart
+// @Description The labels and per-year series /compare/years/chart draws.
+// @Tags compare
+// @Produce json
+// @Param a query int true "First year"
+// @Param b query int true "Second year"
+// @Param resolve_original_year query bool false "Count remasters and compilations towards the year the song was first released"
+// @Param include_playlist_id query []string false "Only use these playlists" collectionFormat(multi)
+// @Param exclude_playlist_id query []string false "Never use these playlists" collectionFormat(multi)
+// @Param include_name_pattern query []string false "Only use playlists whose name matches one of these regular expressions" collectionFormat(multi)
+// @Param exclude_name_pattern query []string false "Skip playlists whose name matches one of these regular expressions" collectionFormat(multi)
+// @Param exclude_name_substring query []string false "Skip playlists whose name contains one of these, ignoring case" collectionFormat(multi)
+// @Param owner query string false "me, others or a Spotify user ID"
+// @Param collaborative query bool false "Only use collaborative (true) or non-collaborative (false) playlists"
+// @Param min_tracks query int false "Skip playlists with fewer tracks"
+// @Param max_tracks query int false "Skip playlists with more tracks"
+// @Success 200 {object} services.BarChartData
+// @Failure 400 {string} string "Invalid year or playlist selection"
+// @Failure 500 {string} string "Failed to fetch library"
+// @Security ApiKeyAuth
+// @Router /compare/years/chart/data [get]
+func GetYearComparisonChartDataHandler(w http.ResponseWriter, r *http.Request) {
+	comparison, status, err := compareYears(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comparisonChartData(r, comparison))
+}
@@ -0,0 +1,181 @@
+package graphs
+
+import (
+	"net/http"
+
+	"github.com/CallumClarke65/spotify-analytics/internal/services"
+	"github.com/CallumClarke65/spotify-analytics/internal/spotifyauth"
+	"github.com/zmb3/spotify/v2"
+	"go.uber.org/zap"
+)
+
+// Where the genre charts take their tracks from
+const (
+	genreSourceTop      = "top"
+	genreSourceLiked    = "liked"
+	genreSourcePlaylist = "playlist"
+)
+
+// genreData counts the genres of the tracks from the source query param: the user's top
+// tracks for time_range (default), their liked songs, or the playlist_id playlist. If it
+// can't, it writes the error and returns false.
+func genreData(w http.ResponseWriter, r *http.Request, client *spotify.Client, opts services.GenreOptions) (services.GenreData, bool) {
+	userName := spotifyauth.UserNameFromContext(r.Context())
+
+	var tracks []spotify.FullTrack
+	var title string
+	switch source := r.URL.Query().Get("source"); source {
+	case "", genreSourceTop:
+		var timeRangeStr string
+		var ok bool
+		tracks, timeRangeStr, ok = topTracksFromRequest(w, r, client)
+		if !ok {
+			return services.GenreData{}, false
+		}
+		title = userName + "'s Top Tracks (" + timeRangeStr + ") - Genres"
+
+	case genreSourceLiked, genreSourcePlaylist:
+		dedupe, err := dedupeRule(r)
+		if err != nil {
+			jsonError(w, "invalid dedupe", http.StatusBadRequest)
+			return services.GenreData{}, false
+		}
+
+		if source == genreSourceLiked {
+			tracks, err = services.GetAllUserSavedTracks(r.Context(), client)
+			title = userName + "'s Liked Songs - Genres"
+		} else {
+			playlistId := r.URL.Query().Get("playlist_id")
+			if playlistId == "" {
+				jsonError(w, "playlist_id is required", http.StatusBadRequest)
+				return services.GenreData{}, false
+			}
+			playlist, lookupErr := client.GetPlaylist(r.Context(), spotify.ID(playlistId))
+			if lookupErr != nil {
+				jsonError(w, "playlist with id "+playlistId+" not found", http.StatusNotFound)
+				return services.GenreData{}, false
+			}
+			tracks, err = services.GetAllPlaylistTracks(r.Context(), client, playlist.SimplePlaylist)
+			title = playlist.Name + " - Genres"
+		}
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return services.GenreData{}, false
+		}
+		tracks, _ = services.DedupeTracks(tracks, dedupe)
+
+	default:
+		jsonError(w, "unknown source "+source+", expected top, liked or playlist", http.StatusBadRequest)
+		return services.GenreData{}, false
+	}
+
+	artists, err := services.GetTrackArtists(r.Context(), client, tracks)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return services.GenreData{}, false
+	}
+	return services.AggregateGenres(tracks, artists, title, opts), true
+}
+
+// GetGenresGraphHandler godoc
+// @Summary Chart of the genres in the user's music
+// @Description Pie, donut or bar chart of the genres of the artists on the user's top tracks, liked songs or a playlist. Each artist counts once towards each of their genres, or with weight=tracks each track counts once towards each genre of its artists. Genres past the top N are folded into "other". Rendered charts are cached for 10 minutes per user, parameters and format.
+// @Tags graphs
+// @Produce image/png,image/svg+xml,image/jpeg
+// @Param source query string false "top (default), liked or playlist"
+// @Param time_range query string false "short_term, medium_term or long_term; required for the top source"
+// @Param playlist_id query string false "Playlist ID; required for the playlist source"
+// @Param weight query string false "artists (default) or tracks"
+// @Param top query int false "Genres shown before the rest are folded into other, 1-50 (default 10)"
+// @Param chart query string false "pie (default), donut or bar"
+// @Param format query string false "png (default), svg or jpeg; overrides the Accept header"
+// @Param width query int false "Width in pixels, 200-4096 (default 1280)"
+// @Param height query int false "Height in pixels, 200-4096 (default 720)"
+// @Param theme query string false "light (default), dark, vivid-light, vivid-dark, nature-light, nature-dark, grafana, ant, retro, ocean, slate, gray, winter, spring, summer or fall"
+// @Param font_size query number false "Label text size, 6-48; titles are drawn larger"
+// @Param dedupe query string false "Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)"
+// @Success 200 {file} binary
+// @Failure 400 {object} map[string]string "Invalid parameter"
+// @Failure 404 {object} map[string]string "Playlist not found"
+// @Failure 500 {object} map[string]string "Failed to fetch tracks or render chart"
+// @Security ApiKeyAuth
+// @Router /graphs/genres [get]
+func GetGenresGraphHandler(w http.ResponseWriter, r *http.Request) {
+	client := spotifyauth.ClientFromContext(r.Context())
+	if client == nil {
+		jsonError(w, "Spotify client missing in context", http.StatusUnauthorized)
+		return
+	}
+
+	format, err := chartFormat(r)
+	if err != nil {
+		jsonError(w, "invalid format", http.StatusBadRequest)
+		return
+	}
+	chartOpts, err := chartOptions(r, format)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts, err := genreOptions(r)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cacheKey := chartCacheKey(r, format)
+	if serveCachedChart(w, cacheKey, format) {
+		return
+	}
+
+	data, ok := genreData(w, r, client, opts)
+	if !ok {
+		return
+	}
+
+	buf, err := services.RenderGenres(data, opts.Chart, chartOpts)
+	if err != nil {
+		zap.L().Error("Failed to render chart to bytes", zap.Error(err))
+		jsonError(w, "failed to render chart", http.StatusInternalServerError)
+		return
+	}
+
+	services.CacheChart(cacheKey, buf)
+	writeChart(w, format, buf)
+}
+
+// GetGenresGraphDataHandler godoc
+// @Summary Data behind the genres chart
+// @Description The genres and counts /graphs/genres draws, largest first with "other" last.
+// @Tags graphs
+// @Produce json
+// @Param source query string false "top (default), liked or playlist"
+// @Param time_range query string false "short_term, medium_term or long_term; required for the top source"
+// @Param playlist_id query string false "Playlist ID; required for the playlist source"
+// @Param weight query string false "artists (default) or tracks"
+// @Param top query int false "Genres listed before the rest are folded into other, 1-50 (default 10)"
+// @Param dedupe query string false "Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)"
+// @Success 200 {object} services.GenreData
+// @Failure 400 {object} map[string]string "Invalid parameter"
+// @Failure 404 {object} map[string]string "Playlist not found"
+// @Failure 500 {object} map[string]string "Failed to fetch tracks"
+// @Security ApiKeyAuth
+// @Router /graphs/genres/data [get]
+func GetGenresGraphDataHandler(w http.ResponseWriter, r *http.Request) {
+	client := spotifyauth.ClientFromContext(r.Context())
+	if client == nil {
+		jsonError(w, "Spotify client missing in context", http.StatusUnauthorized)
+		return
+	}
+
+	opts, err := genreOptions(r)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, ok := genreData(w, r, client, opts)
+	if !ok {
+		return
+	}
+	writeJSON(w, data)
+}
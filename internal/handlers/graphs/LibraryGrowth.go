@@ -0,0 +1,148 @@
+package graphs
+
+import (
+	"net/http"
+
+	"github.com/CallumClarke65/spotify-analytics/internal/services"
+	"github.com/CallumClarke65/spotify-analytics/internal/spotifyauth"
+	"github.com/zmb3/spotify/v2"
+	"go.uber.org/zap"
+)
+
+// libraryGrowthData counts the tracks added each month to the liked songs, unless liked is
+// false, and to each playlist_id playlist. If it can't, it writes the error and returns false.
+func libraryGrowthData(w http.ResponseWriter, r *http.Request, client *spotify.Client) (services.LibraryGrowthData, bool) {
+	includeLiked, err := boolParam(r, "liked", true)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return services.LibraryGrowthData{}, false
+	}
+	playlistIDs, err := playlistIDsParam(r)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return services.LibraryGrowthData{}, false
+	}
+	if !includeLiked && len(playlistIDs) == 0 {
+		jsonError(w, "playlist_id is required when liked is false", http.StatusBadRequest)
+		return services.LibraryGrowthData{}, false
+	}
+
+	var sources []services.GrowthSource
+	if includeLiked {
+		source, err := services.LikedSongsGrowthSource(r.Context(), client)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return services.LibraryGrowthData{}, false
+		}
+		sources = append(sources, source)
+	}
+	for _, playlistId := range playlistIDs {
+		playlist, err := client.GetPlaylist(r.Context(), spotify.ID(playlistId))
+		if err != nil {
+			jsonError(w, "playlist with id "+playlistId+" not found", http.StatusNotFound)
+			return services.LibraryGrowthData{}, false
+		}
+		source, err := services.PlaylistGrowthSource(r.Context(), client, playlist.SimplePlaylist)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return services.LibraryGrowthData{}, false
+		}
+		sources = append(sources, source)
+	}
+
+	data, err := services.AggregateLibraryGrowth(sources, spotifyauth.UserNameFromContext(r.Context())+"'s Library Growth")
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return services.LibraryGrowthData{}, false
+	}
+	return data, true
+}
+
+// GetLibraryGrowthGraphHandler godoc
+// @Summary Chart of how the user's library grew
+// @Description Line chart of how many tracks were in the user's liked songs, and optionally each playlist, by the end of each month, going by when each track was added. Only tracks still there are counted. Bars against the right axis show the tracks added each month across every line. Rendered charts are cached for 10 minutes per user, parameters and format.
+// @Tags graphs
+// @Produce image/png,image/svg+xml,image/jpeg
+// @Param liked query bool false "Include the liked songs (default true)"
+// @Param playlist_id query []string false "Playlists to add a line for, up to 10; repeat the param or separate with commas" collectionFormat(multi)
+// @Param added query bool false "Show the tracks added each month as bars (default true)"
+// @Param format query string false "png (default), svg or jpeg; overrides the Accept header"
+// @Param width query int false "Width in pixels, 200-4096 (default 1280)"
+// @Param height query int false "Height in pixels, 200-4096 (default 720)"
+// @Param theme query string false "light (default), dark, vivid-light, vivid-dark, nature-light, nature-dark, grafana, ant, retro, ocean, slate, gray, winter, spring, summer or fall"
+// @Param font_size query number false "Axis text size, 6-48; titles are drawn larger"
+// @Success 200 {file} binary
+// @Failure 400 {object} map[string]string "Invalid parameter"
+// @Failure 404 {object} map[string]string "Playlist not found"
+// @Failure 500 {object} map[string]string "Failed to fetch tracks or render chart"
+// @Security ApiKeyAuth
+// @Router /graphs/libraryGrowth [get]
+func GetLibraryGrowthGraphHandler(w http.ResponseWriter, r *http.Request) {
+	client := spotifyauth.ClientFromContext(r.Context())
+	if client == nil {
+		jsonError(w, "Spotify client missing in context", http.StatusUnauthorized)
+		return
+	}
+
+	format, err := chartFormat(r)
+	if err != nil {
+		jsonError(w, "invalid format", http.StatusBadRequest)
+		return
+	}
+	chartOpts, err := chartOptions(r, format)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	showAdded, err := boolParam(r, "added", true)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cacheKey := chartCacheKey(r, format)
+	if serveCachedChart(w, cacheKey, format) {
+		return
+	}
+
+	data, ok := libraryGrowthData(w, r, client)
+	if !ok {
+		return
+	}
+
+	buf, err := services.RenderLibraryGrowth(data, showAdded, chartOpts)
+	if err != nil {
+		zap.L().Error("Failed to render chart to bytes", zap.Error(err))
+		jsonError(w, "failed to render chart", http.StatusInternalServerError)
+		return
+	}
+
+	services.CacheChart(cacheKey, buf)
+	writeChart(w, format, buf)
+}
+
+// GetLibraryGrowthGraphDataHandler godoc
+// @Summary Data behind the library growth chart
+// @Description The months, and for the liked songs and each playlist the tracks added each month and the running total, that /graphs/libraryGrowth draws.
+// @Tags graphs
+// @Produce json
+// @Param liked query bool false "Include the liked songs (default true)"
+// @Param playlist_id query []string false "Playlists to add a series for, up to 10; repeat the param or separate with commas" collectionFormat(multi)
+// @Success 200 {object} services.LibraryGrowthData
+// @Failure 400 {object} map[string]string "Invalid parameter"
+// @Failure 404 {object} map[string]string "Playlist not found"
+// @Failure 500 {object} map[string]string "Failed to fetch tracks"
+// @Security ApiKeyAuth
+// @Router /graphs/libraryGrowth/data [get]
+func GetLibraryGrowthGraphDataHandler(w http.ResponseWriter, r *http.Request) {
+	client := spotifyauth.ClientFromContext(r.Context())
+	if client == nil {
+		jsonError(w, "Spotify client missing in context", http.StatusUnauthorized)
+		return
+	}
+
+	data, ok := libraryGrowthData(w, r, client)
+	if !ok {
+		return
+	}
+	writeJSON(w, data)
+}
@@ -9,45 +9,157 @@ import (
 	"go.uber.org/zap"
 )
 
+// playlistTracksByYearData counts the tracks on each playlist_id playlist by release year or
+// decade, a series per playlist, with resolve_original_year and dedupe applied to each. If it
+// can't, it writes the error and returns false.
+func playlistTracksByYearData(w http.ResponseWriter, r *http.Request, client *spotify.Client, bars services.YearBarOptions) (services.BarChartData, bool) {
+	dedupe, err := dedupeRule(r)
+	if err != nil {
+		jsonError(w, "invalid dedupe", http.StatusBadRequest)
+		return services.BarChartData{}, false
+	}
+
+	playlistIDs, err := playlistIDsParam(r)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return services.BarChartData{}, false
+	}
+	if len(playlistIDs) == 0 {
+		jsonError(w, "playlist_id is required", http.StatusBadRequest)
+		return services.BarChartData{}, false
+	}
+
+	var sets []services.YearTrackSet
+	for _, playlistId := range playlistIDs {
+		playlist, err := client.GetPlaylist(r.Context(), spotify.ID(playlistId))
+		if err != nil {
+			jsonError(w, "playlist with id "+playlistId+" not found", http.StatusNotFound)
+			return services.BarChartData{}, false
+		}
+
+		tracks, err := services.GetAllPlaylistTracks(r.Context(), client, playlist.SimplePlaylist)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return services.BarChartData{}, false
+		}
+
+		if resolveOriginalYear(r) {
+			tracks = services.ResolveOriginalReleaseDates(r.Context(), client, tracks, 0)
+		}
+		tracks, _ = services.DedupeTracks(tracks, dedupe)
+		sets = append(sets, services.YearTrackSet{Name: playlist.Name, Tracks: tracks})
+	}
+
+	title := sets[0].Name + " - Tracks by Year"
+	if len(sets) > 1 {
+		title = "Playlist Comparison - Tracks by Year"
+	}
+	data, err := services.AggregateTrackSetsByYear(sets, title, bars)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return services.BarChartData{}, false
+	}
+	return data, true
+}
+
+// GetPlaylistTracksYearGraphHandler godoc
+// @Summary Chart of playlists' tracks by release year
+// @Description Bar chart of how many of the playlist's tracks were released in each year or decade. Given several playlists, each gets its own bars and is named in the legend; percent compares playlists of different sizes. Rendered charts are cached for 10 minutes per user, parameters and format.
+// @Tags graphs
+// @Produce image/png,image/svg+xml,image/jpeg
+// @Param playlist_id query []string true "Playlists to chart, up to 10; repeat the param or separate with commas" collectionFormat(multi)
+// @Param group_by query string false "year (default) or decade"
+// @Param percent query bool false "Show each playlist's bars as a percentage of its tracks"
+// @Param y_unit query number false "Gap between Y axis labels, in tracks or percent"
+// @Param x_unit query number false "Gap between X axis labels, in bars (default 5 for years, 1 for decades)"
+// @Param format query string false "png (default), svg or jpeg; overrides the Accept header"
+// @Param width query int false "Width in pixels, 200-4096 (default 1280)"
+// @Param height query int false "Height in pixels, 200-4096 (default 720)"
+// @Param theme query string false "light (default), dark, vivid-light, vivid-dark, nature-light, nature-dark, grafana, ant, retro, ocean, slate, gray, winter, spring, summer or fall"
+// @Param font_size query number false "Axis text size, 6-48; titles are drawn larger"
+// @Param resolve_original_year query bool false "Count remasters and compilations towards the year the song was first released"
+// @Param dedupe query string false "Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)"
+// @Success 200 {file} binary
+// @Failure 400 {object} map[string]string "Invalid parameter"
+// @Failure 404 {object} map[string]string "Playlist not found"
+// @Failure 500 {object} map[string]string "Failed to fetch tracks or render chart"
+// @Security ApiKeyAuth
+// @Router /graphs/playlistTracksByYear [get]
 func GetPlaylistTracksYearGraphHandler(w http.ResponseWriter, r *http.Request) {
 	client := spotifyauth.ClientFromContext(r.Context())
 	if client == nil {
-		http.Error(w, `{"error":"Spotify client missing in context"}`, http.StatusUnauthorized)
+		jsonError(w, "Spotify client missing in context", http.StatusUnauthorized)
 		return
 	}
 
-	playlistId := r.URL.Query().Get("playlist_id")
-	if playlistId == "" {
-		http.Error(w, `{"error":"playlist_id is required"}`, http.StatusBadRequest)
+	format, err := chartFormat(r)
+	if err != nil {
+		jsonError(w, "invalid format", http.StatusBadRequest)
 		return
 	}
-
-	playlist, err := client.GetPlaylist(r.Context(), spotify.ID(playlistId))
+	chartOpts, err := chartOptions(r, format)
 	if err != nil {
-		http.Error(w, `{"error":"playlist with id `+playlistId+` not found"}`, http.StatusNotFound)
+		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	tracks, err := services.GetAllPlaylistTracks(r.Context(), client, playlist.SimplePlaylist)
+	bars, err := yearBarOptions(r, 5)
 	if err != nil {
-		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusInternalServerError)
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cacheKey := chartCacheKey(r, format)
+	if serveCachedChart(w, cacheKey, format) {
 		return
 	}
 
-	buf, err := services.BarChartTracksByYear(
-		r.Context(),
-		client,
-		tracks,
-		playlist.Name+" - Tracks by Year",
-		5,
-	)
+	data, ok := playlistTracksByYearData(w, r, client, bars)
+	if !ok {
+		return
+	}
 
+	buf, err := services.RenderTracksByYear(data, bars, chartOpts)
 	if err != nil {
 		zap.L().Error("Failed to render chart to bytes", zap.Error(err))
-		http.Error(w, `{"error":"failed to render chart"}`, http.StatusInternalServerError)
+		jsonError(w, "failed to render chart", http.StatusInternalServerError)
+		return
+	}
+
+	services.CacheChart(cacheKey, buf)
+	writeChart(w, format, buf)
+}
+
+// GetPlaylistTracksYearGraphDataHandler godoc
+// @Summary Data behind the playlist tracks by release year chart
+// @Description The labels, year or decade buckets and track counts, or percentages, /graphs/playlistTracksByYear draws, with a series per playlist.
+// @Tags graphs
+// @Produce json
+// @Param playlist_id query []string true "Playlists to count, up to 10; repeat the param or separate with commas" collectionFormat(multi)
+// @Param group_by query string false "year (default) or decade"
+// @Param percent query bool false "Give each playlist's counts as a percentage of its tracks"
+// @Param resolve_original_year query bool false "Count remasters and compilations towards the year the song was first released"
+// @Param dedupe query string false "Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)"
+// @Success 200 {object} services.BarChartData
+// @Failure 400 {object} map[string]string "Invalid parameter"
+// @Failure 404 {object} map[string]string "Playlist not found"
+// @Failure 500 {object} map[string]string "Failed to fetch tracks"
+// @Security ApiKeyAuth
+// @Router /graphs/playlistTracksByYear/data [get]
+func GetPlaylistTracksYearGraphDataHandler(w http.ResponseWriter, r *http.Request) {
+	client := spotifyauth.ClientFromContext(r.Context())
+	if client == nil {
+		jsonError(w, "Spotify client missing in context", http.StatusUnauthorized)
 		return
 	}
 
-	w.Header().Set("Content-Type", "image/png")
-	w.Write(buf)
+	bars, err := yearBarOptions(r, 0)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, ok := playlistTracksByYearData(w, r, client, bars)
+	if !ok {
+		return
+	}
+	writeJSON(w, data)
 }
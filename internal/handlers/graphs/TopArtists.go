@@ -0,0 +1,112 @@
+package graphs
+
+import (
+	"net/http"
+
+	"github.com/CallumClarke65/spotify-analytics/internal/services"
+	"github.com/CallumClarke65/spotify-analytics/internal/spotifyauth"
+	"github.com/zmb3/spotify/v2"
+	"go.uber.org/zap"
+)
+
+// topArtistsData ranks the user's top artists across the long, medium and short term. If it
+// can't, it writes the error and returns false.
+func topArtistsData(w http.ResponseWriter, r *http.Request, client *spotify.Client, opts services.TopArtistsOptions) (services.TopArtistsData, bool) {
+	byRange, err := services.GetTopArtistsByRange(r.Context(), client)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return services.TopArtistsData{}, false
+	}
+	return services.AggregateTopArtists(byRange, spotifyauth.UserNameFromContext(r.Context())+"'s Top Artists", opts), true
+}
+
+// GetTopArtistsGraphHandler godoc
+// @Summary Chart of the user's top artists over time
+// @Description Grouped bar or slope chart of where the user's top artists rank in the long, medium and short term. Artists in the top N of any range are shown. Those that climbed or dropped at least 5 places from long to short term have the change after their name, and on the slope chart are drawn green or red. Rendered charts are cached for 10 minutes per user, parameters and format.
+// @Tags graphs
+// @Produce image/png,image/svg+xml,image/jpeg
+// @Param count query int false "Artists shown, 1-50 (default 10)"
+// @Param chart query string false "bars (default) or slope"
+// @Param format query string false "png (default), svg or jpeg; overrides the Accept header"
+// @Param width query int false "Width in pixels, 200-4096 (default 1280)"
+// @Param height query int false "Height in pixels, 200-4096 (default 720)"
+// @Param theme query string false "light (default), dark, vivid-light, vivid-dark, nature-light, nature-dark, grafana, ant, retro, ocean, slate, gray, winter, spring, summer or fall"
+// @Param font_size query number false "Label text size, 6-48; titles are drawn larger"
+// @Success 200 {file} binary
+// @Failure 400 {object} map[string]string "Invalid parameter"
+// @Failure 500 {object} map[string]string "Failed to fetch top artists or render chart"
+// @Security ApiKeyAuth
+// @Router /graphs/topArtists [get]
+func GetTopArtistsGraphHandler(w http.ResponseWriter, r *http.Request) {
+	client := spotifyauth.ClientFromContext(r.Context())
+	if client == nil {
+		jsonError(w, "Spotify client missing in context", http.StatusUnauthorized)
+		return
+	}
+
+	format, err := chartFormat(r)
+	if err != nil {
+		jsonError(w, "invalid format", http.StatusBadRequest)
+		return
+	}
+	chartOpts, err := chartOptions(r, format)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts, err := topArtistsOptions(r)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cacheKey := chartCacheKey(r, format)
+	if serveCachedChart(w, cacheKey, format) {
+		return
+	}
+
+	data, ok := topArtistsData(w, r, client, opts)
+	if !ok {
+		return
+	}
+
+	buf, err := services.RenderTopArtists(data, opts.Chart, chartOpts)
+	if err != nil {
+		zap.L().Error("Failed to render chart to bytes", zap.Error(err))
+		jsonError(w, "failed to render chart", http.StatusInternalServerError)
+		return
+	}
+
+	services.CacheChart(cacheKey, buf)
+	writeChart(w, format, buf)
+}
+
+// GetTopArtistsGraphDataHandler godoc
+// @Summary Data behind the top artists chart
+// @Description The artists /graphs/topArtists draws, best ranked first, with their rank in each range (null outside the top 50), places climbed from long to short term and whether that makes them rising, falling or steady.
+// @Tags graphs
+// @Produce json
+// @Param count query int false "Artists listed, 1-50 (default 10)"
+// @Success 200 {object} services.TopArtistsData
+// @Failure 400 {object} map[string]string "Invalid parameter"
+// @Failure 500 {object} map[string]string "Failed to fetch top artists"
+// @Security ApiKeyAuth
+// @Router /graphs/topArtists/data [get]
+func GetTopArtistsGraphDataHandler(w http.ResponseWriter, r *http.Request) {
+	client := spotifyauth.ClientFromContext(r.Context())
+	if client == nil {
+		jsonError(w, "Spotify client missing in context", http.StatusUnauthorized)
+		return
+	}
+
+	opts, err := topArtistsOptions(r)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	data, ok := topArtistsData(w, r, client, opts)
+	if !ok {
+		return
+	}
+	writeJSON(w, data)
+}
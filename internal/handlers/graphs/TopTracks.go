@@ -9,103 +9,267 @@ import (
 	"go.uber.org/zap"
 )
 
+// topTracksFromRequest fetches the user's top tracks for the time_range query param, with
+// resolve_original_year and dedupe applied. If it can't, it writes the error and returns
+// false.
+func topTracksFromRequest(w http.ResponseWriter, r *http.Request, client *spotify.Client) ([]spotify.FullTrack, string, bool) {
+	dedupe, err := dedupeRule(r)
+	if err != nil {
+		jsonError(w, "invalid dedupe", http.StatusBadRequest)
+		return nil, "", false
+	}
+
+	timeRange, timeRangeStr, err := timeRangeParam(r)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return nil, "", false
+	}
+
+	tracks, err := services.GetTopTracks(r.Context(), client, timeRange)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return nil, "", false
+	}
+
+	if resolveOriginalYear(r) {
+		tracks = services.ResolveOriginalReleaseDates(r.Context(), client, tracks, 0)
+	}
+	tracks, _ = services.DedupeTracks(tracks, dedupe)
+	return tracks, timeRangeStr, true
+}
+
+// topTracksByYearData counts the user's top tracks by release year or decade
+func topTracksByYearData(w http.ResponseWriter, r *http.Request, client *spotify.Client, bars services.YearBarOptions) (services.BarChartData, bool) {
+	tracks, timeRangeStr, ok := topTracksFromRequest(w, r, client)
+	if !ok {
+		return services.BarChartData{}, false
+	}
+
+	data, err := services.AggregateTracksByYear(
+		tracks,
+		spotifyauth.UserNameFromContext(r.Context())+"'s Top Tracks - "+timeRangeStr,
+		bars,
+	)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return services.BarChartData{}, false
+	}
+	return data, true
+}
+
+// topTracksHeatmapData counts the user's top tracks by release year and popularity
+func topTracksHeatmapData(w http.ResponseWriter, r *http.Request, client *spotify.Client, buckets services.HeatmapOptions) (services.HeatmapData, bool) {
+	tracks, timeRangeStr, ok := topTracksFromRequest(w, r, client)
+	if !ok {
+		return services.HeatmapData{}, false
+	}
+
+	data, err := services.AggregateTracksByYearAndPopularity(
+		tracks,
+		spotifyauth.UserNameFromContext(r.Context())+"'s Top Tracks ("+timeRangeStr+") Year vs Popularity Heatmap",
+		buckets,
+	)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return services.HeatmapData{}, false
+	}
+	return data, true
+}
+
+// GetTopTracksByYearHandler godoc
+// @Summary Chart of the user's top tracks by release year
+// @Description Bar chart of how many of the user's top tracks for the time range were released in each year or decade. Rendered charts are cached for 10 minutes per user, parameters and format.
+// @Tags graphs
+// @Produce image/png,image/svg+xml,image/jpeg
+// @Param time_range query string true "short_term, medium_term or long_term"
+// @Param group_by query string false "year (default) or decade"
+// @Param percent query bool false "Show the bars as a percentage of the tracks"
+// @Param y_unit query number false "Gap between Y axis labels, in tracks or percent"
+// @Param x_unit query number false "Gap between X axis labels, in bars (default 5 for years, 1 for decades)"
+// @Param format query string false "png (default), svg or jpeg; overrides the Accept header"
+// @Param width query int false "Width in pixels, 200-4096 (default 1280)"
+// @Param height query int false "Height in pixels, 200-4096 (default 720)"
+// @Param theme query string false "light (default), dark, vivid-light, vivid-dark, nature-light, nature-dark, grafana, ant, retro, ocean, slate, gray, winter, spring, summer or fall"
+// @Param font_size query number false "Axis text size, 6-48; titles are drawn larger"
+// @Param resolve_original_year query bool false "Count remasters and compilations towards the year the song was first released"
+// @Param dedupe query string false "Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)"
+// @Success 200 {file} binary
+// @Failure 400 {object} map[string]string "Invalid parameter"
+// @Failure 500 {object} map[string]string "Failed to fetch tracks or render chart"
+// @Security ApiKeyAuth
+// @Router /graphs/topTracksByYear [get]
 func GetTopTracksByYearHandler(w http.ResponseWriter, r *http.Request) {
 	client := spotifyauth.ClientFromContext(r.Context())
 	if client == nil {
-		http.Error(w, `{"error":"Spotify client missing in context"}`, http.StatusUnauthorized)
+		jsonError(w, "Spotify client missing in context", http.StatusUnauthorized)
 		return
 	}
 
-	timeRangeStr := r.URL.Query().Get("time_range")
-	if timeRangeStr == "" {
-		http.Error(w, `{"error":"time_range is required"}`, http.StatusBadRequest)
+	format, err := chartFormat(r)
+	if err != nil {
+		jsonError(w, "invalid format", http.StatusBadRequest)
+		return
+	}
+	chartOpts, err := chartOptions(r, format)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	bars, err := yearBarOptions(r, 25)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cacheKey := chartCacheKey(r, format)
+	if serveCachedChart(w, cacheKey, format) {
 		return
 	}
 
-	var timeRange spotify.Range
-	switch timeRangeStr {
-	case "short_term":
-		timeRange = spotify.ShortTermRange
-	case "medium_term":
-		timeRange = spotify.MediumTermRange
-	case "long_term":
-		timeRange = spotify.LongTermRange
-	default:
-		http.Error(w, `{"error":"invalid time_range"}`, http.StatusBadRequest)
+	data, ok := topTracksByYearData(w, r, client, bars)
+	if !ok {
 		return
 	}
 
-	tracks, err := services.GetTopTracks(r.Context(), client, timeRange)
+	buf, err := services.RenderTracksByYear(data, bars, chartOpts)
 	if err != nil {
-		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusInternalServerError)
+		zap.L().Error("Failed to render chart to bytes", zap.Error(err))
+		jsonError(w, "failed to render chart", http.StatusInternalServerError)
 		return
 	}
 
-	buf, err := services.BarChartTracksByYear(
-		r.Context(),
-		client,
-		tracks,
-		spotifyauth.UserNameFromContext(r.Context())+"'s Top Tracks - "+timeRangeStr,
-		25,
-	)
+	services.CacheChart(cacheKey, buf)
+	writeChart(w, format, buf)
+}
 
+// GetTopTracksByYearDataHandler godoc
+// @Summary Data behind the top tracks by release year chart
+// @Description The labels, year or decade buckets and track counts /graphs/topTracksByYear draws.
+// @Tags graphs
+// @Produce json
+// @Param time_range query string true "short_term, medium_term or long_term"
+// @Param group_by query string false "year (default) or decade"
+// @Param percent query bool false "Give the counts as a percentage of the tracks"
+// @Param resolve_original_year query bool false "Count remasters and compilations towards the year the song was first released"
+// @Param dedupe query string false "Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)"
+// @Success 200 {object} services.BarChartData
+// @Failure 400 {object} map[string]string "Invalid parameter"
+// @Failure 500 {object} map[string]string "Failed to fetch tracks"
+// @Security ApiKeyAuth
+// @Router /graphs/topTracksByYear/data [get]
+func GetTopTracksByYearDataHandler(w http.ResponseWriter, r *http.Request) {
+	client := spotifyauth.ClientFromContext(r.Context())
+	if client == nil {
+		jsonError(w, "Spotify client missing in context", http.StatusUnauthorized)
+		return
+	}
+
+	bars, err := yearBarOptions(r, 0)
 	if err != nil {
-		zap.L().Error("Failed to render chart to bytes", zap.Error(err))
-		http.Error(w, `{"error":"failed to render chart"}`, http.StatusInternalServerError)
+		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Content-Type", "image/png")
-	w.Write(buf)
+	data, ok := topTracksByYearData(w, r, client, bars)
+	if !ok {
+		return
+	}
+	writeJSON(w, data)
 }
 
+// GetTopTracksYearPopularityHeatmapHandler godoc
+// @Summary Heatmap of the user's top tracks by release year and popularity
+// @Description Counts the user's top tracks for the time range in release year and Spotify popularity buckets. Rendered charts are cached for 10 minutes per user, parameters and format.
+// @Tags graphs
+// @Produce image/png,image/svg+xml,image/jpeg
+// @Param time_range query string true "short_term, medium_term or long_term"
+// @Param year_bucket query int false "Years per column, 1-50 (default 3)"
+// @Param popularity_bucket query int false "Popularity points per row, 1-100 (default 10)"
+// @Param format query string false "png (default), svg or jpeg; overrides the Accept header"
+// @Param width query int false "Width in pixels, 200-4096 (default 1280)"
+// @Param height query int false "Height in pixels, 200-4096 (default 720)"
+// @Param theme query string false "light (default), dark, vivid-light, vivid-dark, nature-light, nature-dark, grafana, ant, retro, ocean, slate, gray, winter, spring, summer or fall"
+// @Param font_size query number false "Axis text size, 6-48; titles are drawn larger"
+// @Param resolve_original_year query bool false "Count remasters and compilations towards the year the song was first released"
+// @Param dedupe query string false "Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)"
+// @Success 200 {file} binary
+// @Failure 400 {object} map[string]string "Invalid parameter"
+// @Failure 500 {object} map[string]string "Failed to fetch tracks or render chart"
+// @Security ApiKeyAuth
+// @Router /graphs/topTrackHeatmap [get]
 func GetTopTracksYearPopularityHeatmapHandler(w http.ResponseWriter, r *http.Request) {
 	client := spotifyauth.ClientFromContext(r.Context())
 	if client == nil {
-		http.Error(w, `{"error":"Spotify client missing in context"}`, http.StatusUnauthorized)
+		jsonError(w, "Spotify client missing in context", http.StatusUnauthorized)
 		return
 	}
 
-	timeRangeStr := r.URL.Query().Get("time_range")
-	if timeRangeStr == "" {
-		http.Error(w, `{"error":"time_range is required"}`, http.StatusBadRequest)
+	format, err := chartFormat(r)
+	if err != nil {
+		jsonError(w, "invalid format", http.StatusBadRequest)
+		return
+	}
+	chartOpts, err := chartOptions(r, format)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	buckets, err := heatmapOptions(r)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cacheKey := chartCacheKey(r, format)
+	if serveCachedChart(w, cacheKey, format) {
 		return
 	}
 
-	var timeRange spotify.Range
-	switch timeRangeStr {
-	case "short_term":
-		timeRange = spotify.ShortTermRange
-	case "medium_term":
-		timeRange = spotify.MediumTermRange
-	case "long_term":
-		timeRange = spotify.LongTermRange
-	default:
-		http.Error(w, `{"error":"invalid time_range"}`, http.StatusBadRequest)
+	data, ok := topTracksHeatmapData(w, r, client, buckets)
+	if !ok {
 		return
 	}
 
-	tracks, err := services.GetTopTracks(r.Context(), client, timeRange)
+	buf, err := services.RenderHeatmap(data, chartOpts)
 	if err != nil {
-		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusInternalServerError)
+		zap.L().Error("Failed to render chart to bytes", zap.Error(err))
+		jsonError(w, "failed to render chart", http.StatusInternalServerError)
 		return
 	}
 
-	buf, err := services.HeatmapTracksByYearAndPopularity(
-		r.Context(),
-		client,
-		tracks,
-		spotifyauth.UserNameFromContext(r.Context())+"'s Top Tracks ("+timeRangeStr+") Year vs Popularity Heatmap",
-		3,
-		10,
-	)
+	services.CacheChart(cacheKey, buf)
+	writeChart(w, format, buf)
+}
 
+// GetTopTracksYearPopularityHeatmapDataHandler godoc
+// @Summary Data behind the top tracks heatmap
+// @Description The year and popularity buckets and the track count in each cell that /graphs/topTrackHeatmap draws. Rows run from the least popular bucket up.
+// @Tags graphs
+// @Produce json
+// @Param time_range query string true "short_term, medium_term or long_term"
+// @Param year_bucket query int false "Years per column, 1-50 (default 3)"
+// @Param popularity_bucket query int false "Popularity points per row, 1-100 (default 10)"
+// @Param resolve_original_year query bool false "Count remasters and compilations towards the year the song was first released"
+// @Param dedupe query string false "Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)"
+// @Success 200 {object} services.HeatmapData
+// @Failure 400 {object} map[string]string "Invalid parameter"
+// @Failure 500 {object} map[string]string "Failed to fetch tracks"
+// @Security ApiKeyAuth
+// @Router /graphs/topTrackHeatmap/data [get]
+func GetTopTracksYearPopularityHeatmapDataHandler(w http.ResponseWriter, r *http.Request) {
+	client := spotifyauth.ClientFromContext(r.Context())
+	if client == nil {
+		jsonError(w, "Spotify client missing in context", http.StatusUnauthorized)
+		return
+	}
+
+	buckets, err := heatmapOptions(r)
 	if err != nil {
-		zap.L().Error("Failed to render chart to bytes", zap.Error(err))
-		http.Error(w, `{"error":"failed to render chart"}`, http.StatusInternalServerError)
+		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Content-Type", "image/png")
-	w.Write(buf)
+	data, ok := topTracksHeatmapData(w, r, client, buckets)
+	if !ok {
+		return
+	}
+	writeJSON(w, data)
 }
@@ -0,0 +1,341 @@
+package graphs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/CallumClarke65/spotify-analytics/internal/services"
+	"github.com/CallumClarke65/spotify-analytics/internal/spotifyauth"
+	"github.com/zmb3/spotify/v2"
+)
+
+// resolveOriginalYear reads the resolve_original_year query param, which counts remasters
+// and compilations towards the year the song was first released
+func resolveOriginalYear(r *http.Request) bool {
+	resolve, _ := strconv.ParseBool(r.URL.Query().Get("resolve_original_year"))
+	return resolve
+}
+
+// timeRangeParam reads the required time_range query param, returning the range and its name
+func timeRangeParam(r *http.Request) (spotify.Range, string, error) {
+	name := r.URL.Query().Get("time_range")
+	switch name {
+	case "":
+		return "", "", fmt.Errorf("time_range is required")
+	case "short_term":
+		return spotify.ShortTermRange, name, nil
+	case "medium_term":
+		return spotify.MediumTermRange, name, nil
+	case "long_term":
+		return spotify.LongTermRange, name, nil
+	default:
+		return "", "", fmt.Errorf("invalid time_range")
+	}
+}
+
+// dedupeRule reads the dedupe query param, which picks the version kept when the same song
+// appears more than once
+func dedupeRule(r *http.Request) (string, error) {
+	rule := r.URL.Query().Get("dedupe")
+	return rule, services.ValidateDedupeRule(rule)
+}
+
+// chartFormat picks the format to render from the format query param (png, svg, jpeg),
+// falling back to the Accept header
+func chartFormat(r *http.Request) (string, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return services.ParseChartFormat(format)
+	}
+	return services.ChartFormatFromAccept(r.Header.Get("Accept")), nil
+}
+
+// chartCacheParams are the query params that change a chart, and how to normalise each so
+// equivalent requests share a cache entry. Anything else is left out of the cache key, so
+// made-up params can't fill the cache.
+var chartCacheParams = []struct {
+	name string
+	kind string // "list", "repeated", "bool", "number" or "string"
+}{
+	{"playlist_id", "list"},
+	{"a", "number"},
+	{"b", "number"},
+	{"include_playlist_id", "list"},
+	{"exclude_playlist_id", "list"},
+	{"include_name_pattern", "repeated"},
+	{"exclude_name_pattern", "repeated"},
+	{"exclude_name_substring", "list"},
+	{"owner", "string"},
+	{"collaborative", "bool"},
+	{"min_tracks", "number"},
+	{"max_tracks", "number"},
+	{"source", "string"},
+	{"time_range", "string"},
+	{"dedupe", "string"},
+	{"resolve_original_year", "bool"},
+	{"liked", "bool"},
+	{"added", "bool"},
+	{"group_by", "string"},
+	{"percent", "bool"},
+	{"y_unit", "number"},
+	{"x_unit", "number"},
+	{"year_bucket", "number"},
+	{"popularity_bucket", "number"},
+	{"weight", "string"},
+	{"top", "number"},
+	{"count", "number"},
+	{"chart", "string"},
+	{"width", "number"},
+	{"height", "number"},
+	{"theme", "string"},
+	{"font_size", "number"},
+}
+
+// chartCacheKey identifies a rendered chart by user, path, the params in chartCacheParams
+// and format. It's empty, so the chart isn't cached, when the user isn't known.
+func chartCacheKey(r *http.Request, format string) string {
+	userID := spotifyauth.UserIDFromContext(r.Context())
+	if userID == "" {
+		return ""
+	}
+
+	query := r.URL.Query()
+	var b strings.Builder
+	b.WriteString(userID + " " + r.URL.Path + " " + format)
+	for _, param := range chartCacheParams {
+		value := strings.TrimSpace(query.Get(param.name))
+		switch param.kind {
+		case "list":
+			var values []string
+			for _, v := range query[param.name] {
+				for _, item := range strings.Split(v, ",") {
+					if item = strings.TrimSpace(item); item != "" {
+						values = append(values, item)
+					}
+				}
+			}
+			value = strings.Join(values, ",")
+		case "repeated":
+			// Values can contain commas, so each is quoted instead of split
+			var values []string
+			for _, v := range query[param.name] {
+				values = append(values, strconv.Quote(v))
+			}
+			value = strings.Join(values, ",")
+		case "bool":
+			if v, err := strconv.ParseBool(value); err == nil {
+				value = strconv.FormatBool(v)
+			}
+		case "number":
+			if v, err := strconv.ParseFloat(value, 64); err == nil {
+				value = strconv.FormatFloat(v, 'g', -1, 64)
+			}
+		}
+		if value != "" {
+			b.WriteString(" " + param.name + "=" + value)
+		}
+	}
+	return b.String()
+}
+
+// serveCachedChart writes the chart if it was rendered recently and reports whether it did
+func serveCachedChart(w http.ResponseWriter, key, format string) bool {
+	buf, ok := services.CachedChart(key)
+	if !ok {
+		return false
+	}
+	writeChart(w, format, buf)
+	return true
+}
+
+func writeChart(w http.ResponseWriter, format string, buf []byte) {
+	w.Header().Set("Content-Type", services.ChartContentType(format))
+	w.Header().Set("Vary", "Accept")
+	w.Write(buf)
+}
+
+// ChartRenderer fetches what a chart needs and draws it, returning the status to send with
+// any error
+type ChartRenderer func(chartOpts services.ChartOptions) ([]byte, int, error)
+
+// ServeChart gives chart handlers outside this package the same format negotiation, chart
+// options and cache as the graph handlers. Errors are written as plain text, the way those handlers
+// write theirs.
+func ServeChart(w http.ResponseWriter, r *http.Request, render ChartRenderer) {
+	format, err := chartFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	chartOpts, err := chartOptions(r, format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cacheKey := chartCacheKey(r, format)
+	if serveCachedChart(w, cacheKey, format) {
+		return
+	}
+
+	buf, status, err := render(chartOpts)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	services.CacheChart(cacheKey, buf)
+	writeChart(w, format, buf)
+}
+
+// intParam reads an optional integer query param, 0 when it's left out
+func intParam(r *http.Request, name string) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a whole number", name)
+	}
+	return value, nil
+}
+
+// floatParam reads an optional number query param, 0 when it's left out
+func floatParam(r *http.Request, name string) (float64, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return 0, nil
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a number", name)
+	}
+	return value, nil
+}
+
+// boolParam reads an optional true/false query param, fallback when it's left out
+func boolParam(r *http.Request, name string, fallback bool) (bool, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return fallback, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false", name)
+	}
+	return value, nil
+}
+
+// maxChartPlaylists is the most playlists one chart compares, so each keeps its own colour
+const maxChartPlaylists = 10
+
+// playlistIDsParam reads playlist_id, which can be given more than once or comma separated.
+// Repeated IDs are dropped, so a playlist is only fetched and charted once.
+func playlistIDsParam(r *http.Request) ([]string, error) {
+	var ids []string
+	seen := map[string]bool{}
+	for _, value := range r.URL.Query()["playlist_id"] {
+		for _, id := range strings.Split(value, ",") {
+			if id = strings.TrimSpace(id); id != "" && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) > maxChartPlaylists {
+		return nil, fmt.Errorf("at most %d playlists can be charted together", maxChartPlaylists)
+	}
+	return ids, nil
+}
+
+// chartOptions reads the width, height, theme and font_size query params
+func chartOptions(r *http.Request, format string) (services.ChartOptions, error) {
+	opts := services.ChartOptions{Format: format, Theme: r.URL.Query().Get("theme")}
+	var err error
+	if opts.Width, err = intParam(r, "width"); err != nil {
+		return opts, err
+	}
+	if opts.Height, err = intParam(r, "height"); err != nil {
+		return opts, err
+	}
+	if opts.FontSize, err = floatParam(r, "font_size"); err != nil {
+		return opts, err
+	}
+	return opts, opts.Validate()
+}
+
+// yearBarOptions reads the group_by, y_unit, x_unit and percent query params, using
+// defaultYUnit when y_unit is left out of a chart of track counts by year
+func yearBarOptions(r *http.Request, defaultYUnit float64) (services.YearBarOptions, error) {
+	opts := services.YearBarOptions{GroupBy: r.URL.Query().Get("group_by")}
+	var err error
+	if opts.Percent, err = boolParam(r, "percent", false); err != nil {
+		return opts, err
+	}
+	if opts.YUnit, err = floatParam(r, "y_unit"); err != nil {
+		return opts, err
+	}
+	if opts.YUnit == 0 && opts.GroupBy != services.YearGroupDecade && !opts.Percent {
+		opts.YUnit = defaultYUnit
+	}
+	if opts.XUnit, err = floatParam(r, "x_unit"); err != nil {
+		return opts, err
+	}
+	return opts, opts.Validate()
+}
+
+// heatmapOptions reads the year_bucket and popularity_bucket query params
+func heatmapOptions(r *http.Request) (services.HeatmapOptions, error) {
+	var opts services.HeatmapOptions
+	var err error
+	if opts.YearBucketSize, err = intParam(r, "year_bucket"); err != nil {
+		return opts, err
+	}
+	if opts.PopularityBucketSize, err = intParam(r, "popularity_bucket"); err != nil {
+		return opts, err
+	}
+	return opts, opts.Validate()
+}
+
+// genreOptions reads the weight, top and chart query params
+func genreOptions(r *http.Request) (services.GenreOptions, error) {
+	opts := services.GenreOptions{
+		Weight: r.URL.Query().Get("weight"),
+		Chart:  r.URL.Query().Get("chart"),
+	}
+	var err error
+	if opts.TopN, err = intParam(r, "top"); err != nil {
+		return opts, err
+	}
+	return opts, opts.Validate()
+}
+
+// topArtistsOptions reads the count and chart query params
+func topArtistsOptions(r *http.Request) (services.TopArtistsOptions, error) {
+	opts := services.TopArtistsOptions{Chart: r.URL.Query().Get("chart")}
+	var err error
+	if opts.Count, err = intParam(r, "count"); err != nil {
+		return opts, err
+	}
+	// A left out count reads as 0, which means the default, so an explicit 0 is caught here
+	if opts.Count == 0 && r.URL.Query().Get("count") != "" {
+		return opts, errors.New("count must be between 1 and 50")
+	}
+	return opts, opts.Validate()
+}
+
+// writeJSON writes the numbers behind a chart
+func writeJSON(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+// jsonError writes an error in the {"error": "..."} shape the graph handlers use
+func jsonError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
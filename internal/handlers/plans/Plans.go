@@ -0,0 +1,93 @@
+package planHandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/CallumClarke65/spotify-analytics/internal/services"
+	"github.com/CallumClarke65/spotify-analytics/internal/spotifyauth"
+	"github.com/go-chi/chi/v5"
+)
+
+func loadPlanForUser(w http.ResponseWriter, r *http.Request) *services.PlaylistPlan {
+	userID := spotifyauth.UserIDFromContext(r.Context())
+	if userID == "" {
+		http.Error(w, "Failed to identify the Spotify user", http.StatusUnauthorized)
+		return nil
+	}
+
+	plan, err := services.LoadPlaylistPlan(chi.URLParam(r, "planId"))
+	if err != nil {
+		http.Error(w, "Plan not found", http.StatusNotFound)
+		return nil
+	}
+
+	if plan.UserID != userID {
+		http.Error(w, "Plan belongs to a different user", http.StatusForbidden)
+		return nil
+	}
+
+	return plan
+}
+
+// GetPlanHandler godoc
+// @Summary Get a stored playlist plan
+// @Description Returns a playlist plan previously created by a dry run
+// @Tags plans
+// @Produce json
+// @Param planId path string true "Plan ID"
+// @Success 200 {object} services.PlaylistPlan
+// @Failure 401 {string} string "Failed to identify the Spotify user"
+// @Failure 403 {string} string "Plan belongs to a different user"
+// @Failure 404 {string} string "Plan not found"
+// @Security ApiKeyAuth
+// @Router /plans/{planId} [get]
+func GetPlanHandler(w http.ResponseWriter, r *http.Request) {
+	plan := loadPlanForUser(w, r)
+	if plan == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan)
+}
+
+// ApplyPlanHandler godoc
+// @Summary Apply a stored playlist plan
+// @Description Makes exactly the playlist changes recorded in a plan from a dry run. Fails without changing anything if a playlist to be updated was edited after the plan was made.
+// @Description Progress is saved to the plan after every step, so if applying fails part way, applying it again carries on from the step that failed.
+// @Tags plans
+// @Produce json
+// @Param planId path string true "Plan ID"
+// @Success 200 {object} services.PlaylistPlan
+// @Failure 401 {string} string "Failed to identify the Spotify user"
+// @Failure 403 {string} string "Plan belongs to a different user"
+// @Failure 404 {string} string "Plan not found"
+// @Failure 409 {string} string "Plan already applied or playlist changed since the plan was made"
+// @Failure 500 {string} string "Failed to apply plan"
+// @Security ApiKeyAuth
+// @Router /plans/{planId}/apply [post]
+func ApplyPlanHandler(w http.ResponseWriter, r *http.Request) {
+	unlock := services.LockPlaylistPlan(chi.URLParam(r, "planId"))
+	defer unlock()
+
+	plan := loadPlanForUser(w, r)
+	if plan == nil {
+		return
+	}
+
+	client := spotifyauth.ClientFromContext(r.Context())
+	err := services.ApplyPlaylistPlan(r.Context(), client, plan)
+	if errors.Is(err, services.ErrPlaylistPlanAlreadyApplied) || errors.Is(err, services.ErrPlaylistChangedSincePlan) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to apply plan: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan)
+}
@@ -0,0 +1,73 @@
+package suggestionHandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/CallumClarke65/spotify-analytics/internal/services"
+	"github.com/CallumClarke65/spotify-analytics/internal/spotifyauth"
+)
+
+// GetSuggestionDefaultsHandler godoc
+// @Summary Get the user's default suggestion options
+// @Description Returns the suggestion options used when a suggestions request leaves them out
+// @Tags suggestions
+// @Produce json
+// @Success 200 {object} services.SuggestionOptions
+// @Failure 401 {string} string "Failed to identify the Spotify user"
+// @Failure 500 {string} string "Failed to load suggestion defaults"
+// @Security ApiKeyAuth
+// @Router /suggestions/defaults [get]
+func GetSuggestionDefaultsHandler(w http.ResponseWriter, r *http.Request) {
+	defaults, err := services.LoadSuggestionDefaults(spotifyauth.UserIDFromContext(r.Context()))
+	if errors.Is(err, services.ErrNoUserID) {
+		http.Error(w, "Failed to identify the Spotify user", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load suggestion defaults", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(defaults)
+}
+
+// PutSuggestionDefaultsHandler godoc
+// @Summary Set the user's default suggestion options
+// @Description Replaces the suggestion options used when a suggestions request leaves them out
+// @Tags suggestions
+// @Accept json
+// @Produce json
+// @Param body body services.SuggestionOptions true "Default suggestion options"
+// @Success 200 {object} services.SuggestionOptions
+// @Failure 400 {string} string "Invalid JSON body or suggestion options"
+// @Failure 401 {string} string "Failed to identify the Spotify user"
+// @Failure 500 {string} string "Failed to save suggestion defaults"
+// @Security ApiKeyAuth
+// @Router /suggestions/defaults [put]
+func PutSuggestionDefaultsHandler(w http.ResponseWriter, r *http.Request) {
+	var defaults services.SuggestionOptions
+	if err := json.NewDecoder(r.Body).Decode(&defaults); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if err := defaults.Validate(); err != nil {
+		http.Error(w, "Invalid suggestion options: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := services.SaveSuggestionDefaults(spotifyauth.UserIDFromContext(r.Context()), defaults)
+	if errors.Is(err, services.ErrNoUserID) {
+		http.Error(w, "Failed to identify the Spotify user", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to save suggestion defaults", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(defaults)
+}
@@ -0,0 +1,55 @@
+package suggestionHandlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/CallumClarke65/spotify-analytics/internal/services"
+	"github.com/CallumClarke65/spotify-analytics/internal/spotifyauth"
+	"go.uber.org/zap"
+)
+
+// PostSuggestionFeedbackHandler godoc
+// @Summary Give feedback on suggested tracks
+// @Description Likes or dislikes suggested tracks, or blocks their main artist, to tune future suggestions. Disliked tracks are never suggested again, blocked artists are dropped from the seeds, and every 2 liked tracks by an artist give it one more track per run, up to 5 more.
+// @Tags suggestions
+// @Accept json
+// @Produce json
+// @Param body body services.SuggestionFeedbackRequest true "Feedback"
+// @Success 200 {object} services.SuggestionFeedback
+// @Failure 400 {string} string "Invalid JSON body or feedback, or an unknown track ID"
+// @Failure 401 {string} string "Failed to identify the Spotify user"
+// @Failure 500 {string} string "Failed to save suggestion feedback"
+// @Security ApiKeyAuth
+// @Router /suggestions/feedback [post]
+func PostSuggestionFeedbackHandler(w http.ResponseWriter, r *http.Request) {
+	var req services.SuggestionFeedbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, "Invalid feedback: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	client := spotifyauth.ClientFromContext(r.Context())
+	feedback, err := services.RecordSuggestionFeedback(r.Context(), client, spotifyauth.UserIDFromContext(r.Context()), req)
+	if errors.Is(err, services.ErrUnknownTrack) {
+		http.Error(w, "Invalid feedback: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, services.ErrNoUserID) {
+		http.Error(w, "Failed to identify the Spotify user", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		zap.L().Error("Failed to save suggestion feedback", zap.Error(err))
+		http.Error(w, "Failed to save suggestion feedback", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(feedback)
+}
@@ -3,9 +3,9 @@ package yearHandlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -14,22 +14,109 @@ import (
 	"github.com/CallumClarke65/spotify-analytics/internal/spotifyauth"
 	"github.com/go-chi/chi/v5"
 	"github.com/zmb3/spotify/v2"
+	"go.uber.org/zap"
 )
 
 type HasSaveObject interface {
 	GetSaveObject() bool
 }
 
-type TrackFetcher[B any] func(ctx context.Context, client *spotify.Client, body B) ([]spotify.FullTrack, error)
+type HasRanking interface {
+	GetRanking() services.RankingOptions
+}
+
+type HasResolveOriginalYear interface {
+	GetResolveOriginalYear() bool
+}
+
+// HasTrackFields picks the full or compact form of each TrackInfo
+type HasTrackFields interface {
+	GetFields() string
+}
+
+// HasPlaylistSelection is implemented by bodies for endpoints that walk the user's playlists,
+// either for their tracks or to rank by them
+type HasPlaylistSelection interface {
+	GetPlaylistSelection() services.PlaylistSelection
+}
+
+// HasSuggestionOptions is implemented by bodies for endpoints that make suggestions
+type HasSuggestionOptions interface {
+	GetSuggestionOptions() services.SuggestionOptions
+}
+
+// HasDedupe picks which version of a song to keep when it appears more than once
+type HasDedupe interface {
+	GetDedupe() string
+}
+
+type YearRequestBody interface {
+	HasSaveObject
+	HasRanking
+	HasResolveOriginalYear
+	HasTrackFields
+	HasDedupe
+}
+
+// releasePeriodFromRequest reads the {year} and optional {month} URL params, and the
+// optional quarter query param (e.g. quarter=3 or quarter=Q3)
+func releasePeriodFromRequest(r *http.Request) (services.ReleasePeriod, error) {
+	year, err := strconv.Atoi(chi.URLParam(r, "year"))
+	if err != nil {
+		return services.ReleasePeriod{}, errors.New("invalid year")
+	}
+
+	monthStr := chi.URLParam(r, "month")
+	quarterStr := r.URL.Query().Get("quarter")
+
+	switch {
+	case monthStr != "" && quarterStr != "":
+		return services.ReleasePeriod{}, errors.New("can't filter by both month and quarter")
+	case monthStr != "":
+		month, err := strconv.Atoi(monthStr)
+		if err != nil {
+			return services.ReleasePeriod{}, errors.New("invalid month")
+		}
+		return services.MonthPeriod(year, month)
+	case quarterStr != "":
+		quarter, err := services.ParseQuarter(quarterStr)
+		if err != nil {
+			return services.ReleasePeriod{}, err
+		}
+		return services.QuarterPeriod(year, quarter)
+	default:
+		return services.YearPeriod(year), nil
+	}
+}
+
+// PlaylistTracksResponse godoc
+// @Description Tracks, and the playlists picked by the playlist selection that were used to find or rank them
+// @name PlaylistTracksResponse
+type PlaylistTracksResponse struct {
+	Tracks        []services.TrackInfo       `json:"tracks"`
+	PlaylistsUsed []services.PlaylistSummary `json:"playlists_used"`
+}
 
-func BaseYearHandler[B HasSaveObject](fetch TrackFetcher[B]) http.HandlerFunc {
+// SuggestionsResponse godoc
+// @Description Suggested tracks, and how they were picked
+// @name SuggestionsResponse
+type SuggestionsResponse struct {
+	Tracks        []services.TrackInfo       `json:"tracks"`
+	Report        *services.SuggestionReport `json:"report"`
+	PlaylistsUsed []services.PlaylistSummary `json:"playlists_used"`
+}
+
+// TrackFetcher returns the tracks to filter, recording where each one came from
+type TrackFetcher[B any] func(ctx context.Context, client *spotify.Client, body B) (*services.TrackCollection, error)
+
+func BaseYearHandler[B YearRequestBody](fetch TrackFetcher[B]) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		yearStr := chi.URLParam(r, "year")
-		year, err := strconv.Atoi(yearStr)
+		period, err := releasePeriodFromRequest(r)
 		if err != nil {
-			http.Error(w, "Invalid year", http.StatusBadRequest)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		year := period.Year
 
 		var body B
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
@@ -37,32 +124,105 @@ func BaseYearHandler[B HasSaveObject](fetch TrackFetcher[B]) http.HandlerFunc {
 			return
 		}
 
+		ranking := body.GetRanking()
+		if err := ranking.Validate(); err != nil {
+			http.Error(w, "Invalid ranking: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := services.ValidateTrackFields(body.GetFields()); err != nil {
+			http.Error(w, "Invalid fields: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := services.ValidateDedupeRule(body.GetDedupe()); err != nil {
+			http.Error(w, "Invalid dedupe rule: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Bodies without a selection rank against all of the user's playlists
+		var selection services.PlaylistSelection
+		withSelection, hasSelection := any(body).(HasPlaylistSelection)
+		if hasSelection {
+			selection = withSelection.GetPlaylistSelection()
+			if err := selection.Validate(); err != nil {
+				http.Error(w, "Invalid playlist selection: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if withSuggestions, ok := any(body).(HasSuggestionOptions); ok {
+			if err := withSuggestions.GetSuggestionOptions().Validate(); err != nil {
+				http.Error(w, "Invalid suggestion options: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
 		client := spotifyauth.ClientFromContext(r.Context())
-		tracks, err := fetch(r.Context(), client, body)
+		collection, err := fetch(r.Context(), client, body)
+		if errors.Is(err, services.ErrNoUserID) {
+			http.Error(w, "Failed to identify the Spotify user", http.StatusUnauthorized)
+			return
+		}
 		if err != nil {
 			http.Error(w, "Failed to fetch tracks", http.StatusInternalServerError)
 			return
 		}
+		tracks := collection.Tracks
 
-		filtered := services.FilterTracksFromYear(tracks, year)
+		if body.GetResolveOriginalYear() {
+			tracks = services.ResolveOriginalReleaseDates(r.Context(), client, tracks, year)
+		}
+
+		filtered := services.FilterTracksFromPeriod(tracks, period)
+		filtered, duplicates := services.DedupeTracks(filtered, body.GetDedupe())
+		if duplicates > 0 {
+			zap.L().Debug("Dropped duplicate versions", zap.String("period", period.String()), zap.Int("count", duplicates))
+		}
 
 		var result []services.TrackInfo
 		for _, t := range filtered {
-			info := services.GetShortTrackDetails(t)
+			info := services.GetShortTrackDetails(t, body.GetFields(), collection.Provenance(t.ID.String()))
 			result = append(result, info)
 		}
 
-		sort.Slice(result, func(i, j int) bool {
-			return result[i].Popularity > result[j].Popularity
-		})
+		signals, err := services.BuildRankingSignals(r.Context(), client, ranking, selection, collection, body.GetFields() == services.TrackFieldsFull)
+		if err != nil {
+			http.Error(w, "Failed to fetch ranking data", http.StatusInternalServerError)
+			return
+		}
+		signals.MarkTopTracks(collection)
+		if collection.SuggestionReport != nil {
+			services.RankSuggestions(result, collection, ranking, signals)
+			result = services.CapSuggestions(result, collection.SuggestionReport)
+			if err := services.RecordSuggestedTracks(spotifyauth.UserIDFromContext(r.Context()), result); err != nil {
+				zap.L().Warn("Failed to record suggested tracks", zap.Error(err))
+			}
+		} else {
+			services.RankTracks(result, ranking, signals)
+		}
+
+		// Every body that picks playlists gets back the ones that were used
+		var response any = result
+		switch {
+		case collection.SuggestionReport != nil:
+			response = SuggestionsResponse{
+				Tracks:        result,
+				Report:        collection.SuggestionReport,
+				PlaylistsUsed: services.SummarisePlaylists(collection.Playlists),
+			}
+		case hasSelection:
+			response = PlaylistTracksResponse{
+				Tracks:        result,
+				PlaylistsUsed: services.SummarisePlaylists(collection.Playlists),
+			}
+		}
 
 		if body.GetSaveObject() {
 			username := strings.ReplaceAll(spotifyauth.UserNameFromContext(r.Context()), " ", "_")
-			filename := fmt.Sprintf("songs_%d_%s_%s", year, username, time.Now().Format(time.RFC3339))
-			_ = services.WriteJsonObjectToFile(result, filename)
+			filename := fmt.Sprintf("songs_%s_%s_%s", strings.ReplaceAll(period.String(), " ", "_"), username, time.Now().Format(time.RFC3339))
+			_ = services.WriteJsonObjectToFile(response, filename)
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(result)
+		json.NewEncoder(w).Encode(response)
 	}
 }
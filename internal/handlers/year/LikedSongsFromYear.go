@@ -12,30 +12,84 @@ import (
 // @Description Body for fetching liked songs
 // @name LikedSongsBody
 type LikedSongsBody struct {
-	SaveObject bool `json:"saveObject"`
+	SaveObject bool                    `json:"saveObject"`
+	Ranking    services.RankingOptions `json:"ranking"`
+	// The playlists the playlistCount and earliestAdded rankings look at; all of them by default
+	PlaylistSelection services.PlaylistSelection `json:"playlistSelection"`
+	// Count remasters and compilations towards the year the song was first released
+	ResolveOriginalYear bool `json:"resolveOriginalYear"`
+	// "compact" (default) for just the ID, names, release date, popularity and score, or "full" for every field and where the track came from
+	Fields string `json:"fields" example:"full"`
+	// Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)
+	Dedupe string `json:"dedupe" example:"mostPopular"`
 }
 
 func (b LikedSongsBody) GetSaveObject() bool {
 	return b.SaveObject
 }
 
-var LikedSongsFromYear = BaseYearHandler(func(ctx context.Context, client *spotify.Client, body LikedSongsBody) ([]spotify.FullTrack, error) {
-	return services.GetAllUserSavedTracks(ctx, client)
+func (b LikedSongsBody) GetRanking() services.RankingOptions {
+	return b.Ranking
+}
+
+func (b LikedSongsBody) GetResolveOriginalYear() bool {
+	return b.ResolveOriginalYear
+}
+
+func (b LikedSongsBody) GetPlaylistSelection() services.PlaylistSelection {
+	return b.PlaylistSelection
+}
+
+func (b LikedSongsBody) GetFields() string {
+	return b.Fields
+}
+
+func (b LikedSongsBody) GetDedupe() string {
+	return b.Dedupe
+}
+
+var LikedSongsFromYear = BaseYearHandler(func(ctx context.Context, client *spotify.Client, body LikedSongsBody) (*services.TrackCollection, error) {
+	saved, err := services.GetAllUserSavedTrackItems(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	collection := services.NewTrackCollection()
+	collection.AddLiked(saved)
+	return collection, nil
 })
 
 // LikedSongsFromYearHandler godoc
 // @Summary Get liked songs from a specific year
-// @Description Returns a list of liked tracks filtered by year. Optionally saves the results if SaveObject=true.
+// @Description Returns a list of liked tracks filtered by year. Ordered by the ranking strategy in the body (popularity by default). Optionally saves the results if SaveObject=true.
 // @Tags year
 // @Accept json
 // @Produce json
 // @Param year path int true "Year to filter by"
+// @Param quarter query string false "Only tracks released in this quarter, e.g. 3 or Q3"
 // @Param body body LikedSongsBody true "Request body"
-// @Success 200 {array} services.TrackInfo
-// @Failure 400 {string} string "Invalid year or JSON body"
+// @Success 200 {object} PlaylistTracksResponse
+// @Failure 400 {string} string "Invalid year, JSON body, ranking options, playlist selection or fields"
 // @Failure 500 {string} string "Failed to fetch tracks"
 // @Security ApiKeyAuth
 // @Router /year/{year}/likedSongs [post]
 func LikedSongsFromYearHandler(w http.ResponseWriter, r *http.Request) {
 	LikedSongsFromYear(w, r)
 }
+
+// LikedSongsFromMonthHandler godoc
+// @Summary Get liked songs from a specific month
+// @Description Returns a list of liked tracks released in the given month. Tracks whose release date is only known to the year are excluded.
+// @Tags year
+// @Accept json
+// @Produce json
+// @Param year path int true "Year the tracks were released in"
+// @Param month path int true "Month the tracks were released in (1-12)"
+// @Param body body LikedSongsBody true "Request body"
+// @Success 200 {object} PlaylistTracksResponse
+// @Failure 400 {string} string "Invalid year, month, JSON body, ranking options, playlist selection or fields"
+// @Failure 500 {string} string "Failed to fetch tracks"
+// @Security ApiKeyAuth
+// @Router /year/{year}/month/{month}/likedSongs [post]
+func LikedSongsFromMonthHandler(w http.ResponseWriter, r *http.Request) {
+	LikedSongsFromYear(w, r)
+}
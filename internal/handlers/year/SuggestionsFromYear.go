@@ -3,10 +3,9 @@ package yearHandlers
 import (
 	"context"
 	"net/http"
-	"strconv"
 
 	"github.com/CallumClarke65/spotify-analytics/internal/services"
-	"github.com/go-chi/chi/v5"
+	"github.com/CallumClarke65/spotify-analytics/internal/spotifyauth"
 	"github.com/zmb3/spotify/v2"
 )
 
@@ -14,36 +13,114 @@ import (
 // @Description Body for fetching suggested tracks from a year
 // @name SuggestionsFromYearRequestBody
 type SuggestionsFromYearRequestBody struct {
-	SaveObject bool `json:"saveObject"`
+	SaveObject bool                    `json:"saveObject"`
+	Ranking    services.RankingOptions `json:"ranking"`
+	// Falls back to the user's stored defaults (see /suggestions/defaults) for anything left out
+	Suggestions services.SuggestionOptions `json:"suggestions"`
+	// The playlists used for library seeds, album familiarity and the playlistCount and earliestAdded rankings; all of them by default
+	PlaylistSelection services.PlaylistSelection `json:"playlistSelection"`
+	// Count remasters and compilations towards the year the song was first released
+	ResolveOriginalYear bool `json:"resolveOriginalYear"`
+	// "compact" (default) for just the ID, names, release date, popularity and score, or "full" for every field and where the track came from
+	Fields string `json:"fields" example:"full"`
+	// Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)
+	Dedupe string `json:"dedupe" example:"mostPopular"`
 }
 
 func (b SuggestionsFromYearRequestBody) GetSaveObject() bool {
 	return b.SaveObject
 }
 
+func (b SuggestionsFromYearRequestBody) GetRanking() services.RankingOptions {
+	return b.Ranking
+}
+
+func (b SuggestionsFromYearRequestBody) GetResolveOriginalYear() bool {
+	return b.ResolveOriginalYear
+}
+
+func (b SuggestionsFromYearRequestBody) GetPlaylistSelection() services.PlaylistSelection {
+	return b.PlaylistSelection
+}
+
+func (b SuggestionsFromYearRequestBody) GetFields() string {
+	return b.Fields
+}
+
+func (b SuggestionsFromYearRequestBody) GetDedupe() string {
+	return b.Dedupe
+}
+
+func (b SuggestionsFromYearRequestBody) GetSuggestionOptions() services.SuggestionOptions {
+	return b.Suggestions
+}
+
 // SuggestionsFromYearHandler godoc
 // @Summary Get suggested tracks from a specific year
-// @Description Returns a list of suggested tracks for the given year. Optionally saves results if SaveObject=true.
+// @Description Returns suggested tracks for the given year and a report of how they were picked. Seed artists come from the strategies in "suggestions.seeds" (the user's top artists by default), each with its own weight, capped at maxSeedArtists. Optionally saves results if SaveObject=true.
+// @Description Each track has a score combining seed artist affinity, popularity and album diversity, and the reasons it was suggested; tracks are ordered by that score unless a ranking strategy is given.
+// @Description Tracks already in the user's liked songs are never suggested. Neither are tracks that can't be played in the user's country. Every suggestion is remembered, and "suggestions.previouslySuggested" picks whether repeats are ranked lower (default), skipped or included.
+// @Description "suggestions.genres" drops seed artists by genre; the report lists each dropped artist and the rule that dropped it. Search hits by artists other than the seed artist are dropped and counted in the report.
+// @Description Up to "suggestions.tracksPerArtist" tracks (5) are picked per artist and "suggestions.tracksPerAlbum" (3) per album, from the first "suggestions.searchLimit" search results (50). "suggestions.deepCuts" looks further (200 results) and prefers less popular tracks from albums already in the library. "suggestions.maxSuggestions" caps the whole list.
 // @Tags year
 // @Accept json
 // @Produce json
 // @Param year path int true "Year to get suggestions for"
+// @Param quarter query string false "Only tracks released in this quarter, e.g. 3 or Q3"
 // @Param body body SuggestionsFromYearRequestBody true "Request body"
-// @Success 200 {array} services.TrackInfo
-// @Failure 400 {string} string "Invalid year or JSON body"
+// @Success 200 {object} SuggestionsResponse
+// @Failure 400 {string} string "Invalid year, JSON body, ranking options, playlist selection, suggestion options or fields"
+// @Failure 401 {string} string "Failed to identify the Spotify user"
 // @Failure 500 {string} string "Failed to fetch tracks"
 // @Security ApiKeyAuth
 // @Router /year/{year}/suggestions [post]
 func SuggestionsFromYearHandler(w http.ResponseWriter, r *http.Request) {
-	yearStr := chi.URLParam(r, "year")
-	year, err := strconv.Atoi(yearStr)
+	period, err := releasePeriodFromRequest(r)
 	if err != nil {
-		http.Error(w, "Invalid year", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	// Wrap the generic handler
-	BaseYearHandler(func(ctx context.Context, client *spotify.Client, body SuggestionsFromYearRequestBody) ([]spotify.FullTrack, error) {
-		return services.GetSuggestedTracksFromYear(ctx, client, year)
+	BaseYearHandler(func(ctx context.Context, client *spotify.Client, body SuggestionsFromYearRequestBody) (*services.TrackCollection, error) {
+		userID := spotifyauth.UserIDFromContext(ctx)
+		opts, err := services.ResolveSuggestionOptions(userID, body.Suggestions)
+		if err != nil {
+			return nil, err
+		}
+		// Fetched here rather than by the suggestions so the playlists used can be listed
+		lib, err := services.GetLibrary(ctx, client, body.PlaylistSelection)
+		if err != nil {
+			return nil, err
+		}
+		library := append(append([]spotify.FullTrack{}, lib.Liked...), lib.OnPlaylists...)
+		suggested, report, err := services.GetSuggestedTracksFromYear(ctx, client, userID, period.Year, opts, body.PlaylistSelection, library)
+		if err != nil {
+			return nil, err
+		}
+		collection := services.NewTrackCollection()
+		collection.UsePlaylists(lib.Playlists)
+		collection.AddSuggested(suggested)
+		collection.SuggestionReport = report
+		return collection, nil
 	})(w, r)
 }
+
+// SuggestionsFromMonthHandler godoc
+// @Summary Get suggested tracks from a specific month
+// @Description Returns suggested tracks released in the given month, and a report of how they were picked. Tracks whose release date is only known to the year are excluded.
+// @Tags year
+// @Accept json
+// @Produce json
+// @Param year path int true "Year the tracks were released in"
+// @Param month path int true "Month the tracks were released in (1-12)"
+// @Param body body SuggestionsFromYearRequestBody true "Request body"
+// @Success 200 {object} SuggestionsResponse
+// @Failure 400 {string} string "Invalid year, month, JSON body, ranking options, playlist selection, suggestion options or fields"
+// @Failure 401 {string} string "Failed to identify the Spotify user"
+// @Failure 500 {string} string "Failed to fetch tracks"
+// @Security ApiKeyAuth
+// @Router /year/{year}/month/{month}/suggestions [post]
+func SuggestionsFromMonthHandler(w http.ResponseWriter, r *http.Request) {
+	SuggestionsFromYearHandler(w, r)
+}
@@ -3,46 +3,99 @@ package yearHandlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
-	"sort"
-	"strconv"
+	"strings"
+	"time"
 
 	"github.com/CallumClarke65/spotify-analytics/internal/services"
 	"github.com/CallumClarke65/spotify-analytics/internal/spotifyauth"
-	"github.com/go-chi/chi/v5"
 	"github.com/zmb3/spotify/v2"
+	"go.uber.org/zap"
 )
 
 // YearAnalysisRequestBody godoc
 // @Description Request body for performing a full year analysis (on playlists, liked songs, suggestions)
 // @name YearAnalysisRequestBody
 type YearAnalysisRequestBody struct {
-	IgnoredPlaylistNameSubstrings []string `json:"ignoredPlaylistNameSubstrings"`
-	SaveObject                    bool     `json:"saveObject"`
-	MakePlaylists                 bool     `json:"makePlaylists"`
+	// Kept for older clients; the same as playlistSelection.excludeNameSubstrings
+	IgnoredPlaylistNameSubstrings []string                   `json:"ignoredPlaylistNameSubstrings"`
+	PlaylistSelection             services.PlaylistSelection `json:"playlistSelection"`
+	SaveObject                    bool                       `json:"saveObject"`
+	MakePlaylists                 bool                       `json:"makePlaylists"`
+	DryRun                        bool                       `json:"dryRun"`
+	ReplaceExisting               bool                       `json:"replaceExisting"`
+	// Playlists to generate when MakePlaylists is set. Defaults to "{year} - favourites" and "{year} - suggestions".
+	Playlists []services.PlaylistTemplate `json:"playlists"`
+	CoverArt  services.CoverArtOptions    `json:"coverArt"`
+	Ranking   services.RankingOptions     `json:"ranking"`
+	// Falls back to the user's stored defaults (see /suggestions/defaults) for anything left out
+	Suggestions services.SuggestionOptions `json:"suggestions"`
+	// Count remasters and compilations towards the year the song was first released
+	ResolveOriginalYear bool `json:"resolveOriginalYear"`
+	// "compact" (default) for just the ID, names, release date, popularity and score, or "full" for every field and where the track came from
+	Fields string `json:"fields" example:"full"`
+	// Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)
+	Dedupe string `json:"dedupe" example:"mostPopular"`
 }
 
 // YearAnalysisResponse godoc
 // @Description Response from YearAnalysis endpoint
 // @name YearAnalysisResponse
 type YearAnalysisResponse struct {
-	OnPlaylists []services.TrackInfo `json:"on_playlists"`
-	Liked       []services.TrackInfo `json:"liked"`
-	Suggestions []services.TrackInfo `json:"suggestions"`
+	OnPlaylists      []services.TrackInfo       `json:"on_playlists"`
+	Liked            []services.TrackInfo       `json:"liked"`
+	Suggestions      []services.TrackInfo       `json:"suggestions"`
+	PlaylistsUsed    []services.PlaylistSummary `json:"playlists_used"`
+	SuggestionReport *services.SuggestionReport `json:"suggestion_report"`
+	Plan             *services.PlaylistPlan     `json:"plan,omitempty"`
 }
 
 func (b YearAnalysisRequestBody) GetSaveObject() bool {
 	return b.SaveObject
 }
 
+func (b YearAnalysisRequestBody) GetRanking() services.RankingOptions {
+	return b.Ranking
+}
+
+func (b YearAnalysisRequestBody) GetResolveOriginalYear() bool {
+	return b.ResolveOriginalYear
+}
+
+func (b YearAnalysisRequestBody) GetFields() string {
+	return b.Fields
+}
+
+func (b YearAnalysisRequestBody) GetDedupe() string {
+	return b.Dedupe
+}
+
+func (b YearAnalysisRequestBody) GetPlaylistSelection() services.PlaylistSelection {
+	selection := b.PlaylistSelection
+	selection.ExcludeNameSubstrings = append(selection.ExcludeNameSubstrings, b.IgnoredPlaylistNameSubstrings...)
+	return selection
+}
+
 func (b YearAnalysisRequestBody) GetMakePlaylists() bool {
 	return b.MakePlaylists
 }
 
+func (b YearAnalysisRequestBody) GetDryRun() bool {
+	return b.DryRun
+}
+
 func fetchTracksForYear(
 	ctx context.Context,
 	client *spotify.Client,
-	year int,
+	period services.ReleasePeriod,
+	resolveOriginalYear bool,
+	ranking services.RankingOptions,
+	signals *services.RankingSignals,
+	fields string,
+	dedupe string,
+	collection *services.TrackCollection,
+	suggestions bool,
 	fetch func(ctx context.Context, client *spotify.Client) ([]spotify.FullTrack, error),
 ) ([]services.TrackInfo, error) {
 
@@ -51,26 +104,32 @@ func fetchTracksForYear(
 		return nil, err
 	}
 
-	filtered := services.FilterTracksFromYear(tracks, year)
+	if resolveOriginalYear {
+		tracks = services.ResolveOriginalReleaseDates(ctx, client, tracks, period.Year)
+	}
+
+	filtered, _ := services.DedupeTracks(services.FilterTracksFromPeriod(tracks, period), dedupe)
 	result := make([]services.TrackInfo, 0, len(filtered))
 	for _, t := range filtered {
-		result = append(result, services.GetShortTrackDetails(t))
+		result = append(result, services.GetShortTrackDetails(t, fields, collection.Provenance(t.ID.String())))
 	}
 
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].Popularity > result[j].Popularity
-	})
+	if suggestions {
+		services.RankSuggestions(result, collection, ranking, signals)
+	} else {
+		services.RankTracks(result, ranking, signals)
+	}
 
 	return result, nil
 }
 
 var YearAnalysis = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-	yearStr := chi.URLParam(r, "year")
-	year, err := strconv.Atoi(yearStr)
+	period, err := releasePeriodFromRequest(r)
 	if err != nil {
-		http.Error(w, "Invalid year", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	year := period.Year
 
 	var body YearAnalysisRequestBody
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
@@ -78,22 +137,87 @@ var YearAnalysis = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	client := spotifyauth.ClientFromContext(r.Context())
-
-	onPlaylists, _ := fetchTracksForYear(r.Context(), client, year, func(ctx context.Context, client *spotify.Client) ([]spotify.FullTrack, error) {
-		playlists, err := services.GetFilteredUserPlaylists(ctx, client, body.IgnoredPlaylistNameSubstrings)
-		if err != nil {
-			return nil, err
+	templates := body.Playlists
+	if len(templates) == 0 {
+		templates = services.DefaultYearPlaylistTemplates()
+	}
+	for _, t := range templates {
+		if err := t.Validate(); err != nil {
+			http.Error(w, "Invalid playlist template: "+err.Error(), http.StatusBadRequest)
+			return
 		}
-		all := make([]spotify.FullTrack, 0)
-		for _, p := range playlists {
-			tracks, _ := services.GetAllPlaylistTracks(ctx, client, p)
-			all = append(all, tracks...)
+	}
+	if body.CoverArt.Enabled {
+		if err := body.CoverArt.Validate(); err != nil {
+			http.Error(w, "Invalid cover art options: "+err.Error(), http.StatusBadRequest)
+			return
 		}
-		return all, nil
+	}
+	if err := body.Ranking.Validate(); err != nil {
+		http.Error(w, "Invalid ranking: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	selection := body.GetPlaylistSelection()
+	if err := selection.Validate(); err != nil {
+		http.Error(w, "Invalid playlist selection: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := services.ValidateTrackFields(body.Fields); err != nil {
+		http.Error(w, "Invalid fields: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := services.ValidateDedupeRule(body.Dedupe); err != nil {
+		http.Error(w, "Invalid dedupe rule: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := body.Suggestions.Validate(); err != nil {
+		http.Error(w, "Invalid suggestion options: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	userID := spotifyauth.UserIDFromContext(r.Context())
+	suggestionOptions, err := services.ResolveSuggestionOptions(userID, body.Suggestions)
+	if errors.Is(err, services.ErrNoUserID) {
+		http.Error(w, "Failed to identify the Spotify user", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load suggestion defaults", http.StatusInternalServerError)
+		return
+	}
+
+	client := spotifyauth.ClientFromContext(r.Context())
+
+	// Shared by all three fetches so each track's provenance covers every source
+	collection := services.NewTrackCollection()
+
+	playlists, playlistsErr := services.GetSelectedUserPlaylists(r.Context(), client, selection)
+	var playlistTracks []spotify.FullTrack
+	for _, p := range playlists {
+		items, _ := services.GetAllPlaylistItems(r.Context(), client, p)
+		playlistTracks = append(playlistTracks, collection.AddPlaylist(p, items)...)
+	}
+	saved, savedErr := services.GetAllUserSavedTrackItems(r.Context(), client)
+	likedTracks := collection.AddLiked(saved)
+	// Every year's tracks from the library, kept for the cover art's tracks-by-year bars
+	library := append(append([]spotify.FullTrack{}, playlistTracks...), likedTracks...)
+
+	signals, err := services.BuildRankingSignals(r.Context(), client, body.Ranking, selection, collection, body.Fields == services.TrackFieldsFull)
+	if err != nil {
+		http.Error(w, "Failed to fetch ranking data", http.StatusInternalServerError)
+		return
+	}
+	signals.MarkTopTracks(collection)
+
+	// One lookup budget for resolving original release dates across all three fetches
+	resolveCtx := services.WithOriginalReleaseBudget(r.Context())
+
+	onPlaylists, _ := fetchTracksForYear(resolveCtx, client, period, body.ResolveOriginalYear, body.Ranking, signals, body.Fields, body.Dedupe, collection, false, func(ctx context.Context, client *spotify.Client) ([]spotify.FullTrack, error) {
+		return playlistTracks, playlistsErr
 	})
 
-	liked, _ := fetchTracksForYear(r.Context(), client, year, services.GetAllUserSavedTracks)
+	liked, _ := fetchTracksForYear(resolveCtx, client, period, body.ResolveOriginalYear, body.Ranking, signals, body.Fields, body.Dedupe, collection, false, func(ctx context.Context, client *spotify.Client) ([]spotify.FullTrack, error) {
+		return likedTracks, savedErr
+	})
 
 	seen := make(map[string]struct{})
 	for _, t := range onPlaylists {
@@ -103,8 +227,18 @@ var YearAnalysis = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request)
 		seen[t.TrackID] = struct{}{}
 	}
 
-	suggestionsAll, _ := fetchTracksForYear(r.Context(), client, year, func(ctx context.Context, client *spotify.Client) ([]spotify.FullTrack, error) {
-		return services.GetSuggestedTracksFromYear(ctx, client, year)
+	suggestionsAll, _ := fetchTracksForYear(resolveCtx, client, period, body.ResolveOriginalYear, body.Ranking, signals, body.Fields, body.Dedupe, collection, true, func(ctx context.Context, client *spotify.Client) ([]spotify.FullTrack, error) {
+		suggested, report, err := services.GetSuggestedTracksFromYear(ctx, client, userID, year, suggestionOptions, selection, library)
+		if err != nil {
+			return nil, err
+		}
+		collection.AddSuggested(suggested)
+		collection.SuggestionReport = report
+		tracks := make([]spotify.FullTrack, 0, len(suggested))
+		for _, s := range suggested {
+			tracks = append(tracks, s.Track)
+		}
+		return tracks, nil
 	})
 
 	suggestions := make([]services.TrackInfo, 0, len(suggestionsAll))
@@ -114,97 +248,130 @@ var YearAnalysis = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request)
 			seen[t.TrackID] = struct{}{}
 		}
 	}
+	suggestions = services.CapSuggestions(suggestions, collection.SuggestionReport)
+	if err := services.RecordSuggestedTracks(userID, suggestions); err != nil {
+		zap.L().Warn("Failed to record suggested tracks", zap.Error(err))
+	}
 
 	if body.GetSaveObject() {
 		username := spotifyauth.UserNameFromContext(r.Context())
 		_ = services.WriteJsonObjectToFile(map[string]interface{}{
-			"on_playlists": onPlaylists,
-			"liked":        liked,
-			"suggestions":  suggestions,
-		}, "year_analysis_"+strconv.Itoa(year)+"_"+username)
+			"on_playlists":   onPlaylists,
+			"liked":          liked,
+			"suggestions":    suggestions,
+			"playlists_used": services.SummarisePlaylists(collection.Playlists),
+		}, "year_analysis_"+strings.ReplaceAll(period.String(), " ", "_")+"_"+username)
 	}
 
-	if body.GetMakePlaylists() {
-		userId := spotifyauth.UserIDFromContext(r.Context())
+	response := YearAnalysisResponse{
+		OnPlaylists:      onPlaylists,
+		Liked:            liked,
+		Suggestions:      suggestions,
+		PlaylistsUsed:    services.SummarisePlaylists(collection.Playlists),
+		SuggestionReport: collection.SuggestionReport,
+	}
 
-		// Combine liked + onPlaylists for "favourites"
-		favouritesTracks := append(onPlaylists, liked...)
+	if body.GetMakePlaylists() {
 
-		// Convert to Spotify IDs
-		favTrackIDs := make([]spotify.ID, 0, len(favouritesTracks))
-		for _, t := range favouritesTracks {
-			favTrackIDs = append(favTrackIDs, spotify.ID(t.TrackID))
+		vars := services.PlaylistTemplateVars{
+			Year:   year,
+			Period: period.String(),
+			User:   spotifyauth.UserNameFromContext(r.Context()),
+			Date:   time.Now(),
+		}
+		buckets := map[string][]services.TrackInfo{
+			services.BucketOnPlaylists: onPlaylists,
+			services.BucketLiked:       liked,
+			services.BucketSuggestions: suggestions,
 		}
 
-		sugTrackIDs := make([]spotify.ID, 0, len(suggestions))
-		for _, t := range suggestions {
-			sugTrackIDs = append(sugTrackIDs, spotify.ID(t.TrackID))
+		var yearCounts map[int]float64
+		if body.CoverArt.Enabled {
+			yearCounts = services.CountTracksByYear(library)
 		}
 
-		// Create playlists
-		favPlaylist, err := client.CreatePlaylistForUser(
-			r.Context(), userId,
-			strconv.Itoa(year)+" - favourites",
-			"Generated playlist of favourites for "+strconv.Itoa(year),
-			false,
-			false,
-		)
-		if err != nil {
-			http.Error(w, "Failed to create favourites playlist: "+err.Error(), http.StatusInternalServerError)
-			return
+		generated := make([]services.GeneratedPlaylist, 0, len(templates))
+		for _, t := range templates {
+			g := services.RenderPlaylistTemplate(t, vars, buckets, body.Dedupe)
+			if body.CoverArt.Enabled {
+				cover, err := services.RenderPlaylistCover(body.CoverArt, year, g.Name, yearCounts)
+				if err != nil {
+					http.Error(w, "Failed to render playlist cover: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+				g.CoverImage = cover
+			}
+			generated = append(generated, g)
 		}
 
-		sugPlaylist, err := client.CreatePlaylistForUser(
-			r.Context(), userId,
-			strconv.Itoa(year)+" - suggestions",
-			"Generated playlist of suggested tracks for "+strconv.Itoa(year),
-			false,
-			false,
-		)
+		plan, err := services.NewPlaylistPlan(r.Context(), client, userID, generated, body.ReplaceExisting)
 		if err != nil {
-			http.Error(w, "Failed to create suggestions playlist: "+err.Error(), http.StatusInternalServerError)
+			http.Error(w, "Failed to plan playlists: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// Add tracks in batches of 100 (Spotify API limit)
-		batchSize := 100
-		for i := 0; i < len(favTrackIDs); i += batchSize {
-			end := i + batchSize
-			if end > len(favTrackIDs) {
-				end = len(favTrackIDs)
+		if body.GetDryRun() {
+			if err := services.SavePlaylistPlan(plan); err != nil {
+				http.Error(w, "Failed to save playlist plan: "+err.Error(), http.StatusInternalServerError)
+				return
 			}
-			client.AddTracksToPlaylist(r.Context(), favPlaylist.ID, favTrackIDs[i:end]...)
-		}
-		for i := 0; i < len(sugTrackIDs); i += batchSize {
-			end := i + batchSize
-			if end > len(sugTrackIDs) {
-				end = len(sugTrackIDs)
+		} else {
+			unlock := services.LockPlaylistPlan(plan.PlanID)
+			err := services.ApplyPlaylistPlan(r.Context(), client, plan)
+			unlock()
+			if err != nil {
+				http.Error(w, "Failed to create playlists: "+err.Error()+"; finish with POST /plans/"+plan.PlanID+"/apply", http.StatusInternalServerError)
+				return
 			}
-			client.AddTracksToPlaylist(r.Context(), sugPlaylist.ID, sugTrackIDs[i:end]...)
 		}
+
+		response.Plan = plan
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"on_playlists": onPlaylists,
-		"liked":        liked,
-		"suggestions":  suggestions,
-	})
+	json.NewEncoder(w).Encode(response)
 })
 
 // YearAnalysisHandler godoc
 // @Summary Perform full year analysis
 // @Description Combines tracks from playlists and liked songs, fetches suggestions, optionally saves JSON, and optionally creates Spotify playlists.
+// @Description Generated playlists are configured with "playlists": name/description templates, visibility, and which buckets (liked, onPlaylists, suggestions) each one contains. Tracks that can't be played in the user's country are left out of generated playlists, and flagged with playable=false in the results.
+// @Description With coverArt.enabled=true each playlist gets a generated cover showing the year and the library's tracks by year.
+// @Description "playlistSelection" picks which playlists count as on_playlists (by ID, name pattern, owner, collaborative flag and track count); the response lists the playlists used.
+// @Description "suggestions" configures how suggestions are built (seed artist strategies and genre filters); suggestions are ordered by their suggestion score and carry the reasons they were picked, falling back to the user's stored defaults; suggestion_report explains what was dropped.
+// @Description "dedupe" keeps one version of songs that appear more than once (album, single, remaster, live), in each bucket and in each generated playlist: the most popular, the earliest release or the album version. By default ("none") they're all kept.
+// @Description With dryRun=true the playlist changes are returned as a plan and stored instead of applied; apply it later with POST /plans/{planId}/apply.
 // @Tags year
 // @Accept json
 // @Produce json
 // @Param year path int true "Year to analyze"
+// @Param quarter query string false "Only tracks released in this quarter, e.g. 3 or Q3"
 // @Param body body YearAnalysisRequestBody true "Request body"
 // @Success 200 {object} YearAnalysisResponse
-// @Failure 400 {string} string "Invalid year or JSON body"
+// @Failure 400 {string} string "Invalid year, JSON body, playlist template, cover art, ranking options, playlist selection, suggestion options or fields"
+// @Failure 401 {string} string "Failed to identify the Spotify user"
 // @Failure 500 {string} string "Failed to fetch tracks or create playlists"
 // @Security ApiKeyAuth
 // @Router /year/{year}/analysis [post]
 func YearAnalysisHandler(w http.ResponseWriter, r *http.Request) {
 	YearAnalysis(w, r)
 }
+
+// MonthAnalysisHandler godoc
+// @Summary Perform full analysis for a single month
+// @Description Same as the year analysis, narrowed to tracks released in the given month. Tracks whose release date is only known to the year are excluded.
+// @Tags year
+// @Accept json
+// @Produce json
+// @Param year path int true "Year the tracks were released in"
+// @Param month path int true "Month the tracks were released in (1-12)"
+// @Param body body YearAnalysisRequestBody true "Request body"
+// @Success 200 {object} YearAnalysisResponse
+// @Failure 400 {string} string "Invalid year, month, JSON body, playlist template, cover art, ranking options, playlist selection, suggestion options or fields"
+// @Failure 401 {string} string "Failed to identify the Spotify user"
+// @Failure 500 {string} string "Failed to fetch tracks or create playlists"
+// @Security ApiKeyAuth
+// @Router /year/{year}/month/{month}/analysis [post]
+func MonthAnalysisHandler(w http.ResponseWriter, r *http.Request) {
+	YearAnalysis(w, r)
+}
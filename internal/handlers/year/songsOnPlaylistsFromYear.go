@@ -12,37 +12,91 @@ import (
 // @Description Request body for fetching tracks from playlists filtered by year
 // @name SongsOnPlaylistsFromYearRequestBody
 type SongsOnPlaylistsFromYearRequestBody struct {
-	IgnoredPlaylistNameSubstrings []string `json:"ignoredPlaylistNameSubstrings"`
-	SaveObject                    bool     `json:"saveObject"`
+	// Kept for older clients; the same as playlistSelection.excludeNameSubstrings
+	IgnoredPlaylistNameSubstrings []string                   `json:"ignoredPlaylistNameSubstrings"`
+	PlaylistSelection             services.PlaylistSelection `json:"playlistSelection"`
+	SaveObject                    bool                       `json:"saveObject"`
+	Ranking                       services.RankingOptions    `json:"ranking"`
+	// Count remasters and compilations towards the year the song was first released
+	ResolveOriginalYear bool `json:"resolveOriginalYear"`
+	// "compact" (default) for just the ID, names, release date, popularity and score, or "full" for every field and where the track came from
+	Fields string `json:"fields" example:"full"`
+	// Which version to keep when the same song appears more than once: mostPopular, earliest, album or none (default)
+	Dedupe string `json:"dedupe" example:"mostPopular"`
 }
 
 func (b SongsOnPlaylistsFromYearRequestBody) GetSaveObject() bool {
 	return b.SaveObject
 }
 
-var SongsOnPlaylistsFromYear = BaseYearHandler(func(ctx context.Context, client *spotify.Client, body SongsOnPlaylistsFromYearRequestBody) ([]spotify.FullTrack, error) {
-	playlists, _ := services.GetFilteredUserPlaylists(ctx, client, body.IgnoredPlaylistNameSubstrings)
-	var all []spotify.FullTrack
+func (b SongsOnPlaylistsFromYearRequestBody) GetRanking() services.RankingOptions {
+	return b.Ranking
+}
+
+func (b SongsOnPlaylistsFromYearRequestBody) GetResolveOriginalYear() bool {
+	return b.ResolveOriginalYear
+}
+
+func (b SongsOnPlaylistsFromYearRequestBody) GetFields() string {
+	return b.Fields
+}
+
+func (b SongsOnPlaylistsFromYearRequestBody) GetDedupe() string {
+	return b.Dedupe
+}
+
+func (b SongsOnPlaylistsFromYearRequestBody) GetPlaylistSelection() services.PlaylistSelection {
+	selection := b.PlaylistSelection
+	selection.ExcludeNameSubstrings = append(selection.ExcludeNameSubstrings, b.IgnoredPlaylistNameSubstrings...)
+	return selection
+}
+
+var SongsOnPlaylistsFromYear = BaseYearHandler(func(ctx context.Context, client *spotify.Client, body SongsOnPlaylistsFromYearRequestBody) (*services.TrackCollection, error) {
+	playlists, err := services.GetSelectedUserPlaylists(ctx, client, body.GetPlaylistSelection())
+	if err != nil {
+		return nil, err
+	}
+	collection := services.NewTrackCollection()
 	for _, p := range playlists {
-		tracks, _ := services.GetAllPlaylistTracks(ctx, client, p)
-		all = append(all, tracks...)
+		items, _ := services.GetAllPlaylistItems(ctx, client, p)
+		collection.AddPlaylist(p, items)
 	}
-	return all, nil
+	return collection, nil
 })
 
 // SongsOnPlaylistsFromYearHandler godoc
 // @Summary Get tracks from user playlists filtered by year
-// @Description Returns tracks from all playlists, excluding ones with ignored substrings. Optionally saves results if SaveObject=true.
+// @Description Returns tracks from the playlists picked by playlistSelection (all playlists by default), along with the playlists that were used.
+// @Description Playlists can be picked by ID, name pattern, owner, collaborative flag and track count. Tracks are ordered by the ranking strategy in the body (popularity by default). Optionally saves results if SaveObject=true.
 // @Tags year
 // @Accept json
 // @Produce json
 // @Param year path int true "Year to filter by"
+// @Param quarter query string false "Only tracks released in this quarter, e.g. 3 or Q3"
 // @Param body body SongsOnPlaylistsFromYearRequestBody true "Request body"
-// @Success 200 {array} services.TrackInfo
-// @Failure 400 {string} string "Invalid year or JSON body"
+// @Success 200 {object} PlaylistTracksResponse
+// @Failure 400 {string} string "Invalid year, JSON body, ranking options, playlist selection or fields"
 // @Failure 500 {string} string "Failed to fetch tracks"
 // @Security ApiKeyAuth
 // @Router /year/{year}/songsFromPlaylists [post]
 func SongsOnPlaylistsFromYearHandler(w http.ResponseWriter, r *http.Request) {
 	SongsOnPlaylistsFromYear(w, r)
 }
+
+// SongsOnPlaylistsFromMonthHandler godoc
+// @Summary Get tracks from user playlists filtered by month
+// @Description Returns tracks released in the given month from the playlists picked by playlistSelection, along with the playlists that were used. Tracks whose release date is only known to the year are excluded.
+// @Tags year
+// @Accept json
+// @Produce json
+// @Param year path int true "Year the tracks were released in"
+// @Param month path int true "Month the tracks were released in (1-12)"
+// @Param body body SongsOnPlaylistsFromYearRequestBody true "Request body"
+// @Success 200 {object} PlaylistTracksResponse
+// @Failure 400 {string} string "Invalid year, month, JSON body, ranking options, playlist selection or fields"
+// @Failure 500 {string} string "Failed to fetch tracks"
+// @Security ApiKeyAuth
+// @Router /year/{year}/month/{month}/songsFromPlaylists [post]
+func SongsOnPlaylistsFromMonthHandler(w http.ResponseWriter, r *http.Request) {
+	SongsOnPlaylistsFromYear(w, r)
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// How long a rendered chart is served from memory before the data is fetched again
+	chartCacheTTL = 10 * time.Minute
+	// Most memory the cached charts can take up; the least recently used go first
+	maxChartCacheBytes = 64 << 20
+)
+
+type cachedChart struct {
+	bytes    []byte
+	expires  time.Time
+	lastUsed time.Time
+}
+
+var (
+	chartCache      = map[string]*cachedChart{} // key includes the format, so each is cached separately
+	chartCacheBytes int
+	chartCacheLock  sync.Mutex
+)
+
+// CachedChart returns a chart rendered for the key within the last chartCacheTTL. An
+// empty key is never cached.
+func CachedChart(key string) ([]byte, bool) {
+	if key == "" {
+		return nil, false
+	}
+
+	chartCacheLock.Lock()
+	defer chartCacheLock.Unlock()
+
+	entry, ok := chartCache[key]
+	if !ok {
+		return nil, false
+	}
+	now := time.Now()
+	if now.After(entry.expires) {
+		removeCachedChart(key)
+		return nil, false
+	}
+	entry.lastUsed = now
+	return entry.bytes, true
+}
+
+// CacheChart keeps a rendered chart for chartCacheTTL, clearing out expired ones and then
+// the least recently used until the cache fits in maxChartCacheBytes. An empty key is
+// never cached.
+func CacheChart(key string, chart []byte) {
+	if key == "" || len(chart) > maxChartCacheBytes {
+		return
+	}
+
+	chartCacheLock.Lock()
+	defer chartCacheLock.Unlock()
+
+	now := time.Now()
+	removeCachedChart(key)
+	for k, entry := range chartCache {
+		if now.After(entry.expires) {
+			removeCachedChart(k)
+		}
+	}
+	for chartCacheBytes+len(chart) > maxChartCacheBytes {
+		oldest := ""
+		for k, entry := range chartCache {
+			if oldest == "" || entry.lastUsed.Before(chartCache[oldest].lastUsed) {
+				oldest = k
+			}
+		}
+		removeCachedChart(oldest)
+	}
+
+	chartCache[key] = &cachedChart{bytes: chart, expires: now.Add(chartCacheTTL), lastUsed: now}
+	chartCacheBytes += len(chart)
+}
+
+// removeCachedChart drops a chart from the cache. Callers hold chartCacheLock.
+func removeCachedChart(key string) {
+	if entry, ok := chartCache[key]; ok {
+		chartCacheBytes -= len(entry.bytes)
+		delete(chartCache, key)
+	}
+}
@@ -0,0 +1,44 @@
+package services
+
+// ChartSeries godoc
+// @Description One named series of a chart, with a value for each label
+// @name ChartSeries
+type ChartSeries struct {
+	Name   string    `json:"name" example:"Tracks"`
+	Values []float64 `json:"values"`
+}
+
+// ChartBucket godoc
+// @Description The range of values counted under one label, both ends included
+// @name ChartBucket
+type ChartBucket struct {
+	Label string `json:"label" example:"1990s"`
+	Start int    `json:"start" example:"1990"`
+	End   int    `json:"end" example:"1999"`
+}
+
+// BarChartData godoc
+// @Description The numbers a bar chart is drawn from. Each series has one value per label.
+// @name BarChartData
+type BarChartData struct {
+	Title   string        `json:"title"`
+	XTitle  string        `json:"x_title,omitempty"`
+	YTitle  string        `json:"y_title,omitempty"`
+	Labels  []string      `json:"labels"`
+	Buckets []ChartBucket `json:"buckets,omitempty"` // what each label covers, for axes of ranges
+	Series  []ChartSeries `json:"series"`
+}
+
+// HeatmapData godoc
+// @Description The numbers a heatmap is drawn from. values has a row for each y bucket, lowest first, and a column for each x bucket.
+// @name HeatmapData
+type HeatmapData struct {
+	Title    string        `json:"title"`
+	XTitle   string        `json:"x_title"`
+	YTitle   string        `json:"y_title"`
+	XLabels  []string      `json:"x_labels"`
+	YLabels  []string      `json:"y_labels"`
+	XBuckets []ChartBucket `json:"x_buckets"`
+	YBuckets []ChartBucket `json:"y_buckets"`
+	Values   [][]float64   `json:"values"`
+}
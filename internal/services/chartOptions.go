@@ -0,0 +1,175 @@
+package services
+
+import (
+	"fmt"
+	"mime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-analyze/charts"
+)
+
+const (
+	ChartFormatPNG  = "png"
+	ChartFormatSVG  = "svg"
+	ChartFormatJPEG = "jpeg"
+
+	defaultChartWidth  = 1280
+	defaultChartHeight = 720
+	minChartSize       = 200
+	maxChartSize       = 4096
+	minChartFontSize   = 6
+	maxChartFontSize   = 48
+	// Titles are drawn this much larger than the axis text
+	chartTitleFontScale = 1.5
+)
+
+// ChartThemes are the themes a chart can be drawn with
+var ChartThemes = []string{
+	charts.ThemeLight, charts.ThemeDark,
+	charts.ThemeVividLight, charts.ThemeVividDark,
+	charts.ThemeNatureLight, charts.ThemeNatureDark,
+	charts.ThemeGrafana, charts.ThemeAnt, charts.ThemeRetro, charts.ThemeOcean,
+	charts.ThemeSlate, charts.ThemeGray,
+	charts.ThemeWinter, charts.ThemeSpring, charts.ThemeSummer, charts.ThemeFall,
+}
+
+// ChartOptions controls how a chart is rendered. Zero values use the defaults.
+type ChartOptions struct {
+	Format   string  // png (default), svg or jpeg
+	Width    int     // 1280 by default
+	Height   int     // 720 by default
+	Theme    string  // one of ChartThemes, light by default
+	FontSize float64 // axis text size; titles are drawn larger
+}
+
+func (o ChartOptions) Validate() error {
+	if _, err := ParseChartFormat(o.Format); err != nil {
+		return err
+	}
+	if o.Width != 0 && (o.Width < minChartSize || o.Width > maxChartSize) {
+		return fmt.Errorf("width must be between %d and %d", minChartSize, maxChartSize)
+	}
+	if o.Height != 0 && (o.Height < minChartSize || o.Height > maxChartSize) {
+		return fmt.Errorf("height must be between %d and %d", minChartSize, maxChartSize)
+	}
+	if o.FontSize != 0 && (o.FontSize < minChartFontSize || o.FontSize > maxChartFontSize) {
+		return fmt.Errorf("font_size must be between %d and %d", minChartFontSize, maxChartFontSize)
+	}
+	if o.Theme != "" && !slices.Contains(ChartThemes, o.Theme) {
+		return fmt.Errorf("unknown theme %q, expected one of %s", o.Theme, strings.Join(ChartThemes, ", "))
+	}
+	return nil
+}
+
+// ParseChartFormat reads a format query param, accepting "jpg" for jpeg
+func ParseChartFormat(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", ChartFormatPNG:
+		return ChartFormatPNG, nil
+	case ChartFormatSVG:
+		return ChartFormatSVG, nil
+	case ChartFormatJPEG, "jpg":
+		return ChartFormatJPEG, nil
+	default:
+		return "", fmt.Errorf("unknown format %q, expected png, svg or jpeg", format)
+	}
+}
+
+// ChartFormatFromAccept picks the format the Accept header prefers most, or png when it
+// doesn't name one of the image types charts can be rendered as
+func ChartFormatFromAccept(accept string) string {
+	type mediaRange struct {
+		format string
+		q      float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if raw, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(raw, 64); err == nil {
+				q = parsed
+			}
+		}
+
+		var format string
+		switch mediaType {
+		case "image/svg+xml":
+			format = ChartFormatSVG
+		case "image/jpeg":
+			format = ChartFormatJPEG
+		case "image/png", "image/*", "*/*":
+			format = ChartFormatPNG
+		default:
+			continue
+		}
+		if q > 0 {
+			ranges = append(ranges, mediaRange{format, q})
+		}
+	}
+
+	if len(ranges) == 0 {
+		return ChartFormatPNG
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+	return ranges[0].format
+}
+
+// ChartContentType is the Content-Type of a chart rendered in the format
+func ChartContentType(format string) string {
+	switch format {
+	case ChartFormatSVG:
+		return "image/svg+xml"
+	case ChartFormatJPEG:
+		return "image/jpeg"
+	default:
+		return "image/png"
+	}
+}
+
+func (o ChartOptions) painterOptions() charts.PainterOptions {
+	outputFormat := charts.ChartOutputPNG
+	switch o.Format {
+	case ChartFormatSVG:
+		outputFormat = charts.ChartOutputSVG
+	case ChartFormatJPEG:
+		outputFormat = charts.ChartOutputJPG
+	}
+	opts := charts.PainterOptions{
+		OutputFormat: outputFormat,
+		Width:        defaultChartWidth,
+		Height:       defaultChartHeight,
+		Theme:        o.theme(),
+	}
+	if o.Width != 0 {
+		opts.Width = o.Width
+	}
+	if o.Height != 0 {
+		opts.Height = o.Height
+	}
+	return opts
+}
+
+func (o ChartOptions) theme() charts.ColorPalette {
+	if o.Theme == "" {
+		return charts.GetTheme(charts.ThemeLight)
+	}
+	return charts.GetTheme(o.Theme)
+}
+
+// font is the font style for text drawn scale times the requested size, or the library's
+// default when no size was asked for
+func (o ChartOptions) font(scale float64) charts.FontStyle {
+	if o.FontSize == 0 {
+		return charts.FontStyle{}
+	}
+	return charts.FontStyle{FontSize: o.FontSize * scale}
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/go-analyze/charts"
+	"github.com/zmb3/spotify/v2"
+	"go.uber.org/zap"
+)
+
+// Library is the user's liked songs and the tracks on their playlists, fetched once so
+// several years can be worked out from it
+type Library struct {
+	Liked       []spotify.FullTrack
+	OnPlaylists []spotify.FullTrack
+	Playlists   []spotify.SimplePlaylist
+}
+
+// YearSummary godoc
+// @Description How much of the library was released in one year
+// @name YearSummary
+type YearSummary struct {
+	Year              int     `json:"year"`
+	LikedCount        int     `json:"liked_count"`
+	OnPlaylistsCount  int     `json:"on_playlists_count"`
+	TotalCount        int     `json:"total_count"` // liked or on a playlist, counted once
+	AveragePopularity float64 `json:"average_popularity"`
+}
+
+// ArtistYearCount godoc
+// @Description An artist and how many of their tracks from each compared year are in the library
+// @name ArtistYearCount
+type ArtistYearCount struct {
+	ArtistID string `json:"artist_id"`
+	Name     string `json:"name"`
+	CountA   int    `json:"count_a"`
+	CountB   int    `json:"count_b"`
+}
+
+// YearComparison godoc
+// @Description Two release years of the library side by side
+// @name YearComparison
+type YearComparison struct {
+	A                YearSummary       `json:"a"`
+	B                YearSummary       `json:"b"`
+	SharedArtists    []ArtistYearCount `json:"shared_artists"`
+	OnlyInA          []ArtistYearCount `json:"only_in_a"`
+	OnlyInB          []ArtistYearCount `json:"only_in_b"`
+	PopularityChange float64           `json:"popularity_change"` // B's average popularity minus A's
+	PlaylistsUsed    []PlaylistSummary `json:"playlists_used"`
+}
+
+// GetLibrary fetches the user's liked songs and the tracks on the selected playlists
+func GetLibrary(ctx context.Context, client *spotify.Client, selection PlaylistSelection) (Library, error) {
+	liked, err := GetAllUserSavedTracks(ctx, client)
+	if err != nil {
+		return Library{}, err
+	}
+
+	playlists, err := GetSelectedUserPlaylists(ctx, client, selection)
+	if err != nil {
+		return Library{}, err
+	}
+
+	var onPlaylists []spotify.FullTrack
+	for _, p := range playlists {
+		tracks, err := GetAllPlaylistTracks(ctx, client, p)
+		if err != nil {
+			zap.L().Warn("Failed to fetch playlist tracks", zap.String("playlist_name", p.Name), zap.Error(err))
+			continue
+		}
+		onPlaylists = append(onPlaylists, tracks...)
+	}
+
+	return Library{Liked: liked, OnPlaylists: onPlaylists, Playlists: playlists}, nil
+}
+
+// CompareYears summarises both years from the same library
+func CompareYears(library Library, a, b int) YearComparison {
+	summaryA, artistsA := summariseYear(library, a)
+	summaryB, artistsB := summariseYear(library, b)
+
+	comparison := YearComparison{
+		A:                summaryA,
+		B:                summaryB,
+		SharedArtists:    []ArtistYearCount{},
+		OnlyInA:          []ArtistYearCount{},
+		OnlyInB:          []ArtistYearCount{},
+		PopularityChange: summaryB.AveragePopularity - summaryA.AveragePopularity,
+		PlaylistsUsed:    SummarisePlaylists(library.Playlists),
+	}
+
+	for id, artist := range artistsA {
+		if other, ok := artistsB[id]; ok {
+			artist.CountB = other.CountA
+			comparison.SharedArtists = append(comparison.SharedArtists, artist)
+		} else {
+			comparison.OnlyInA = append(comparison.OnlyInA, artist)
+		}
+	}
+	for id, artist := range artistsB {
+		if _, ok := artistsA[id]; !ok {
+			comparison.OnlyInB = append(comparison.OnlyInB, ArtistYearCount{
+				ArtistID: artist.ArtistID,
+				Name:     artist.Name,
+				CountB:   artist.CountA,
+			})
+		}
+	}
+
+	sortArtistYearCounts(comparison.SharedArtists)
+	sortArtistYearCounts(comparison.OnlyInA)
+	sortArtistYearCounts(comparison.OnlyInB)
+
+	return comparison
+}
+
+// summariseYear counts the year's tracks, and each artist's tracks in CountA
+func summariseYear(library Library, year int) (YearSummary, map[string]ArtistYearCount) {
+	period := YearPeriod(year)
+	liked := FilterTracksFromPeriod(library.Liked, period)
+	onPlaylists := FilterTracksFromPeriod(library.OnPlaylists, period)
+
+	summary := YearSummary{
+		Year:             year,
+		LikedCount:       len(liked),
+		OnPlaylistsCount: len(onPlaylists),
+	}
+
+	artists := map[string]ArtistYearCount{}
+	seen := map[spotify.ID]bool{}
+	popularityTotal := 0
+	for _, t := range append(liked, onPlaylists...) {
+		if seen[t.ID] {
+			continue
+		}
+		seen[t.ID] = true
+		summary.TotalCount++
+		popularityTotal += int(t.Popularity)
+
+		for _, artist := range t.Artists {
+			count := artists[artist.ID.String()]
+			count.ArtistID = artist.ID.String()
+			count.Name = artist.Name
+			count.CountA++
+			artists[artist.ID.String()] = count
+		}
+	}
+
+	if summary.TotalCount > 0 {
+		summary.AveragePopularity = float64(popularityTotal) / float64(summary.TotalCount)
+	}
+
+	return summary, artists
+}
+
+// sortArtistYearCounts puts the artists with the most tracks across both years first
+func sortArtistYearCounts(artists []ArtistYearCount) {
+	sort.Slice(artists, func(i, j int) bool {
+		ti := artists[i].CountA + artists[i].CountB
+		tj := artists[j].CountA + artists[j].CountB
+		if ti != tj {
+			return ti > tj
+		}
+		return artists[i].Name < artists[j].Name
+	})
+}
+
+// AggregateYearComparison lays a comparison out as one series per year, with a value for
+// each of liked, on-playlist and total tracks, and artists
+func AggregateYearComparison(comparison YearComparison, graphTitle string) BarChartData {
+	return BarChartData{
+		Title:  graphTitle,
+		Labels: []string{"Liked", "On playlists", "Total tracks", "Artists"},
+		Series: []ChartSeries{
+			{
+				Name: strconv.Itoa(comparison.A.Year),
+				Values: []float64{
+					float64(comparison.A.LikedCount),
+					float64(comparison.A.OnPlaylistsCount),
+					float64(comparison.A.TotalCount),
+					float64(len(comparison.SharedArtists) + len(comparison.OnlyInA)),
+				},
+			},
+			{
+				Name: strconv.Itoa(comparison.B.Year),
+				Values: []float64{
+					float64(comparison.B.LikedCount),
+					float64(comparison.B.OnPlaylistsCount),
+					float64(comparison.B.TotalCount),
+					float64(len(comparison.SharedArtists) + len(comparison.OnlyInB)),
+				},
+			},
+		},
+	}
+}
+
+// RenderYearComparison draws the comparison's series side by side, labelled with their values
+func RenderYearComparison(data BarChartData, chartOpts ChartOptions) ([]byte, error) {
+	values := make([][]float64, len(data.Series))
+	names := make([]string, len(data.Series))
+	for i, series := range data.Series {
+		values[i] = series.Values
+		names[i] = series.Name
+	}
+
+	opt := charts.NewBarChartOptionWithData(values)
+	opt.Theme = chartOpts.theme()
+
+	opt.XAxis.Labels = data.Labels
+	opt.XAxis.LabelFontStyle = chartOpts.font(1)
+	opt.Legend.SeriesNames = names
+	opt.Legend.Offset = charts.OffsetRight
+	opt.Legend.FontStyle = chartOpts.font(1)
+	for i := range opt.SeriesList {
+		opt.SeriesList[i].Label.Show = charts.Ptr(true)
+		opt.SeriesList[i].Label.FontStyle = chartOpts.font(1)
+	}
+
+	opt.Title.Text = data.Title
+	opt.Title.FontStyle = chartOpts.font(chartTitleFontScale)
+	opt.YAxis[0].Min = charts.Ptr(0.0)
+	opt.YAxis[0].LabelFontStyle = chartOpts.font(1)
+
+	painter := charts.NewPainter(chartOpts.painterOptions())
+	if err := painter.BarChart(opt); err != nil {
+		zap.L().Error("Failed to build chart", zap.Error(err))
+		return nil, fmt.Errorf("failed to build chart: %w", err)
+	}
+
+	return painter.Bytes()
+}
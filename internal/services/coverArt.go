@@ -0,0 +1,233 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-analyze/charts"
+	"go.uber.org/zap"
+)
+
+const (
+	CoverTemplateChart   = "chart"
+	CoverTemplateMinimal = "minimal"
+
+	// Spotify caps uploaded covers at 256KB, which a 640px JPEG stays well under
+	coverSize = 640
+)
+
+var hexColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// CoverArtOptions godoc
+// @Description Cover image rendered for generated playlists. Colours accept hex (#1db954), rgb(...) or common names.
+// @name CoverArtOptions
+type CoverArtOptions struct {
+	Enabled         bool   `json:"enabled"`
+	Template        string `json:"template" example:"chart"` // "chart" (year and tracks-by-year bars) or "minimal" (year only)
+	BackgroundColor string `json:"backgroundColor" example:"#191414"`
+	TextColor       string `json:"textColor" example:"#ffffff"`
+	BarColor        string `json:"barColor" example:"#535353"`
+	HighlightColor  string `json:"highlightColor" example:"#1db954"`
+}
+
+type coverColors struct {
+	background charts.Color
+	text       charts.Color
+	bar        charts.Color
+	highlight  charts.Color
+}
+
+func (o CoverArtOptions) Validate() error {
+	switch o.Template {
+	case "", CoverTemplateChart, CoverTemplateMinimal:
+	default:
+		return fmt.Errorf("unknown cover template %q", o.Template)
+	}
+	_, err := o.colors()
+	return err
+}
+
+func (o CoverArtOptions) colors() (coverColors, error) {
+	result := coverColors{
+		background: charts.ColorFromHex("191414"),
+		text:       charts.ColorWhite,
+		bar:        charts.ColorFromHex("535353"),
+		highlight:  charts.ColorFromHex("1db954"),
+	}
+
+	for _, c := range []struct {
+		raw    string
+		target *charts.Color
+	}{
+		{o.BackgroundColor, &result.background},
+		{o.TextColor, &result.text},
+		{o.BarColor, &result.bar},
+		{o.HighlightColor, &result.highlight},
+	} {
+		if c.raw == "" {
+			continue
+		}
+		parsed, err := parseCoverColor(c.raw)
+		if err != nil {
+			return result, err
+		}
+		*c.target = parsed
+	}
+
+	return result, nil
+}
+
+// parseCoverColor wraps charts.ParseColor, which panics on malformed hex and quietly
+// turns unknown names into black
+func parseCoverColor(raw string) (charts.Color, error) {
+	raw = strings.TrimSpace(raw)
+	switch {
+	case strings.HasPrefix(raw, "#"):
+		if !hexColorPattern.MatchString(raw) {
+			return charts.Color{}, fmt.Errorf("invalid colour %q", raw)
+		}
+	case strings.HasPrefix(raw, "rgb"):
+		return parseRGBColor(raw)
+	default:
+		if !strings.EqualFold(raw, "black") && charts.ParseColor(raw) == charts.ColorBlack {
+			return charts.Color{}, fmt.Errorf("invalid colour %q", raw)
+		}
+	}
+	return charts.ParseColor(raw), nil
+}
+
+// parseRGBColor parses rgb(r, g, b) with each component from 0 to 255. charts.ParseColor
+// doesn't check the count, range or closing paren, and wraps out of range values.
+func parseRGBColor(raw string) (charts.Color, error) {
+	inner, ok := strings.CutPrefix(raw, "rgb(")
+	if ok {
+		inner, ok = strings.CutSuffix(inner, ")")
+	}
+	parts := strings.Split(inner, ",")
+	if !ok || len(parts) != 3 {
+		return charts.Color{}, fmt.Errorf("invalid colour %q, expected rgb(r, g, b)", raw)
+	}
+	var rgb [3]uint8
+	for i, part := range parts {
+		value, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || value < 0 || value > 255 {
+			return charts.Color{}, fmt.Errorf("invalid colour %q, components must be 0-255", raw)
+		}
+		rgb[i] = uint8(value)
+	}
+	return charts.ColorRGB(rgb[0], rgb[1], rgb[2]), nil
+}
+
+// RenderPlaylistCover draws a square JPEG card with the year in large type, the playlist
+// name beneath it and, for the chart template, a bar per release year from yearCounts with
+// the playlist's year highlighted.
+func RenderPlaylistCover(
+	opts CoverArtOptions,
+	year int,
+	playlistName string,
+	yearCounts map[int]float64,
+) ([]byte, error) {
+	colors, err := opts.colors()
+	if err != nil {
+		return nil, err
+	}
+
+	painter := charts.NewPainter(charts.PainterOptions{
+		OutputFormat: charts.ChartOutputJPG,
+		Width:        coverSize,
+		Height:       coverSize,
+	})
+	painter.FilledRect(0, 0, coverSize, coverSize, colors.background, colors.background, 0)
+
+	margin := 48
+	yearFontSize := 150.0
+	yearTop := margin
+	if opts.Template == CoverTemplateMinimal {
+		yearFontSize = 170
+		yearTop = coverSize/2 - 140
+	}
+
+	// TextFit positions text by its baseline, so offset each block by its first line's height
+	yearStyle := charts.FontStyle{FontSize: yearFontSize, FontColor: colors.text}
+	yearHeight := painter.MeasureText(strconv.Itoa(year), 0, yearStyle).Height()
+	painter.TextFit(strconv.Itoa(year), margin, yearTop+yearHeight, coverSize-2*margin, yearStyle)
+
+	nameStyle := charts.FontStyle{FontSize: 28, FontColor: colors.text}
+	nameHeight := painter.MeasureText(playlistName, 0, nameStyle).Height()
+	painter.TextFit(playlistName, margin, yearTop+yearHeight+24+nameHeight, coverSize-2*margin, nameStyle)
+
+	if opts.Template != CoverTemplateMinimal && len(yearCounts) > 0 {
+		chart := painter.Child(charts.PainterBoxOption(charts.NewBox(
+			margin, coverSize/2+margin, coverSize-margin, coverSize-margin,
+		)))
+		drawYearBars(chart, year, yearCounts, colors)
+	}
+
+	buf, err := painter.Bytes()
+	if err != nil {
+		zap.L().Error("Failed to render playlist cover", zap.Error(err))
+		return nil, err
+	}
+	return buf, nil
+}
+
+func drawYearBars(p *charts.Painter, highlightYear int, yearCounts map[int]float64, colors coverColors) {
+	years := make([]int, 0, len(yearCounts))
+	for y := range yearCounts {
+		years = append(years, y)
+	}
+	sort.Ints(years)
+
+	minYear := years[0]
+	maxYear := years[len(years)-1]
+	if highlightYear < minYear {
+		minYear = highlightYear
+	}
+	if highlightYear > maxYear {
+		maxYear = highlightYear
+	}
+
+	maxCount := 0.0
+	for _, c := range yearCounts {
+		if c > maxCount {
+			maxCount = c
+		}
+	}
+
+	slots := maxYear - minYear + 1
+	slotWidth := float64(p.Width()) / float64(slots)
+	gap := 0
+	if slotWidth >= 6 {
+		gap = 2
+	}
+
+	for y := minYear; y <= maxYear; y++ {
+		count := yearCounts[y]
+		barHeight := 0
+		if maxCount > 0 {
+			barHeight = int(count / maxCount * float64(p.Height()))
+		}
+		// Always show the highlighted year, even if it has no tracks
+		if y == highlightYear && barHeight < 4 {
+			barHeight = 4
+		}
+		if barHeight == 0 {
+			continue
+		}
+
+		color := colors.bar
+		if y == highlightYear {
+			color = colors.highlight
+		}
+
+		x1 := int(float64(y-minYear) * slotWidth)
+		x2 := int(float64(y-minYear+1)*slotWidth) - gap
+		if x2 <= x1 {
+			x2 = x1 + 1
+		}
+		p.FilledRect(x1, p.Height()-barHeight, x2, p.Height(), color, color, 0)
+	}
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/go-analyze/charts"
+)
+
+func TestParseCoverColor(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    charts.Color
+		wantErr bool
+	}{
+		{"#1db954", charts.ColorRGB(0x1d, 0xb9, 0x54), false},
+		{"#fff", charts.ColorRGB(255, 255, 255), false},
+		{"#12345", charts.Color{}, true},
+		{"rgb(29, 185, 84)", charts.ColorRGB(29, 185, 84), false},
+		{"rgb(0,0,0)", charts.ColorRGB(0, 0, 0), false},
+		{"rgb(29, 185)", charts.Color{}, true},
+		{"rgb(29, 185, 84, 1)", charts.Color{}, true},
+		{"rgb(29, 185, 256)", charts.Color{}, true},
+		{"rgb(-1, 185, 84)", charts.Color{}, true},
+		{"rgb(29, 185, 84", charts.Color{}, true},
+		{"rgb(a, b, c)", charts.Color{}, true},
+		{"rgba(29, 185, 84, 0.5)", charts.Color{}, true},
+		{"black", charts.ColorBlack, false},
+		{"notacolour", charts.Color{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := parseCoverColor(tt.raw)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseCoverColor(%q) = %v, %v; want %v, error %v", tt.raw, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// Which version of a song to keep when the same recording appears more than once
+const (
+	DedupeNone        = "none"
+	DedupeMostPopular = "mostPopular"
+	DedupeEarliest    = "earliest"
+	DedupePreferAlbum = "album"
+)
+
+// ValidateDedupeRule checks a dedupe rule; empty means none
+func ValidateDedupeRule(rule string) error {
+	switch rule {
+	case "", DedupeNone, DedupeMostPopular, DedupeEarliest, DedupePreferAlbum:
+		return nil
+	default:
+		return fmt.Errorf("unknown dedupe rule %q, expected one of %s, %s, %s or %s",
+			rule, DedupeMostPopular, DedupeEarliest, DedupePreferAlbum, DedupeNone)
+	}
+}
+
+// trackVersion is what the dedupe engine needs to know about one track, whichever type it
+// came from
+type trackVersion struct {
+	isrc        string
+	title       string // normalised, without version suffixes
+	artist      string // normalised primary artist name
+	popularity  int
+	releaseDate string
+	albumType   string
+	playable    bool
+}
+
+// versionKeys are the keys a track is grouped by. Tracks sharing either one are versions of
+// the same song.
+func (v trackVersion) keys() []string {
+	var keys []string
+	if v.isrc != "" {
+		keys = append(keys, "isrc:"+v.isrc)
+	}
+	if v.title != "" && v.artist != "" {
+		keys = append(keys, "title:"+v.title+"\x00"+v.artist)
+	}
+	return keys
+}
+
+func versionOfFullTrack(t spotify.FullTrack) trackVersion {
+	v := trackVersion{
+		isrc:        t.ExternalIDs["isrc"],
+		title:       NormaliseTrackTitle(t.Name),
+		popularity:  int(t.Popularity),
+		releaseDate: t.Album.ReleaseDate,
+		albumType:   t.Album.AlbumType,
+		playable:    IsPlayable(t),
+	}
+	if len(t.Artists) > 0 {
+		v.artist = NormaliseName(t.Artists[0].Name)
+	}
+	return v
+}
+
+// versionOfTrackInfo reads the compact form too, which has no ISRC or album type
+func versionOfTrackInfo(t TrackInfo) trackVersion {
+	v := trackVersion{
+		isrc:        t.ISRC,
+		title:       NormaliseTrackTitle(t.TrackName),
+		popularity:  t.Popularity,
+		releaseDate: t.ReleaseDate,
+		albumType:   t.AlbumType,
+		playable:    t.Playable == nil || *t.Playable,
+	}
+	if len(t.Artists) > 0 {
+		v.artist = NormaliseName(t.Artists[0])
+	}
+	return v
+}
+
+// preferred reports whether a should be kept over b under the rule, always picking a
+// version the user can play. Ties keep whichever came first.
+func preferred(a, b trackVersion, rule string) bool {
+	if a.playable != b.playable {
+		return a.playable
+	}
+	switch rule {
+	case DedupeEarliest:
+		da, okA := ParseReleaseDate(a.releaseDate, "")
+		db, okB := ParseReleaseDate(b.releaseDate, "")
+		if okA != okB {
+			return okA
+		}
+		if okA && da != db {
+			return da.Before(db)
+		}
+	case DedupePreferAlbum:
+		if (a.albumType == "album") != (b.albumType == "album") {
+			return a.albumType == "album"
+		}
+	}
+	return a.popularity > b.popularity
+}
+
+// dedupeVersions returns the indices of the versions to keep, in the order each song first
+// appears
+func dedupeVersions(versions []trackVersion, rule string) []int {
+	// Union-find over the indices, joined through shared keys
+	parent := make([]int, len(versions))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	byKey := map[string]int{}
+	for i, v := range versions {
+		for _, key := range v.keys() {
+			if j, ok := byKey[key]; ok {
+				// Keep the earliest index as the root so groups stay in first-seen order
+				ri, rj := find(i), find(j)
+				if ri != rj {
+					parent[max(ri, rj)] = min(ri, rj)
+				}
+				continue
+			}
+			byKey[key] = i
+		}
+	}
+
+	best := map[int]int{} // group root -> index of the version kept
+	var roots []int
+	for i := range versions {
+		root := find(i)
+		current, ok := best[root]
+		if !ok {
+			roots = append(roots, root)
+			best[root] = i
+			continue
+		}
+		if preferred(versions[i], versions[current], rule) {
+			best[root] = i
+		}
+	}
+
+	kept := make([]int, 0, len(roots))
+	for _, root := range roots {
+		kept = append(kept, best[root])
+	}
+	return kept
+}
+
+// DedupeTracks collapses versions of the same song (same ISRC, or the same title and main
+// artist once suffixes like "- Remastered 2011" or "(Live)" are dropped) down to one,
+// picked by the rule. It returns the tracks kept and how many were dropped. An empty rule
+// keeps every track.
+func DedupeTracks(tracks []spotify.FullTrack, rule string) ([]spotify.FullTrack, int) {
+	if rule == "" || rule == DedupeNone {
+		return tracks, 0
+	}
+	versions := make([]trackVersion, len(tracks))
+	for i, t := range tracks {
+		versions[i] = versionOfFullTrack(t)
+	}
+
+	kept := dedupeVersions(versions, rule)
+	result := make([]spotify.FullTrack, 0, len(kept))
+	for _, i := range kept {
+		result = append(result, tracks[i])
+	}
+	return result, len(tracks) - len(result)
+}
+
+// DedupeTrackInfos is DedupeTracks for track info that's already been built
+func DedupeTrackInfos(tracks []TrackInfo, rule string) ([]TrackInfo, int) {
+	if rule == "" || rule == DedupeNone {
+		return tracks, 0
+	}
+	versions := make([]trackVersion, len(tracks))
+	for i, t := range tracks {
+		versions[i] = versionOfTrackInfo(t)
+	}
+
+	kept := dedupeVersions(versions, rule)
+	result := make([]TrackInfo, 0, len(kept))
+	for _, i := range kept {
+		result = append(result, tracks[i])
+	}
+	return result, len(tracks) - len(result)
+}
+
+// versionIndex answers whether a track is a version of any song in a set
+type versionIndex map[string]bool
+
+func newVersionIndex(tracks []spotify.FullTrack) versionIndex {
+	index := versionIndex{}
+	for _, t := range tracks {
+		for _, key := range versionOfFullTrack(t).keys() {
+			index[key] = true
+		}
+	}
+	return index
+}
+
+func (idx versionIndex) contains(track spotify.FullTrack) bool {
+	for _, key := range versionOfFullTrack(track).keys() {
+		if idx[key] {
+			return true
+		}
+	}
+	return false
+}
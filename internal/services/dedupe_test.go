@@ -0,0 +1,175 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+func TestDedupeVersions(t *testing.T) {
+	tests := []struct {
+		name     string
+		versions []trackVersion
+		rule     string
+		want     []int
+	}{
+		{"empty", nil, DedupeMostPopular, []int{}},
+		{
+			"different songs all kept",
+			[]trackVersion{
+				{isrc: "A", title: "dreams", artist: "fleetwood mac", playable: true},
+				{isrc: "B", title: "rhiannon", artist: "fleetwood mac", playable: true},
+			},
+			DedupeMostPopular,
+			[]int{0, 1},
+		},
+		{
+			"same isrc keeps the most popular",
+			[]trackVersion{
+				{isrc: "A", title: "dreams", artist: "fleetwood mac", popularity: 40, playable: true},
+				{isrc: "A", title: "dreams", artist: "fleetwood mac", popularity: 80, playable: true},
+			},
+			DedupeMostPopular,
+			[]int{1},
+		},
+		{
+			"same title and artist without an isrc",
+			[]trackVersion{
+				{title: "dreams", artist: "fleetwood mac", popularity: 80, playable: true},
+				{title: "dreams", artist: "fleetwood mac", popularity: 40, playable: true},
+			},
+			DedupeMostPopular,
+			[]int{0},
+		},
+		{
+			"same title by another artist kept",
+			[]trackVersion{
+				{title: "hallelujah", artist: "leonard cohen", playable: true},
+				{title: "hallelujah", artist: "jeff buckley", playable: true},
+			},
+			DedupeMostPopular,
+			[]int{0, 1},
+		},
+		{
+			"joined through a shared key",
+			[]trackVersion{
+				{isrc: "A", title: "dreams", artist: "fleetwood mac", popularity: 10, playable: true},
+				{isrc: "B", title: "dreams", artist: "fleetwood mac", popularity: 20, playable: true},
+				{isrc: "B", title: "dreams live", artist: "fleetwood mac", popularity: 30, playable: true},
+			},
+			DedupeMostPopular,
+			[]int{2},
+		},
+		{
+			"ties keep the first",
+			[]trackVersion{
+				{isrc: "A", popularity: 50, playable: true},
+				{isrc: "A", popularity: 50, playable: true},
+			},
+			DedupeMostPopular,
+			[]int{0},
+		},
+		{
+			"playable beats popular",
+			[]trackVersion{
+				{isrc: "A", popularity: 90, playable: false},
+				{isrc: "A", popularity: 10, playable: true},
+			},
+			DedupeMostPopular,
+			[]int{1},
+		},
+		{
+			"earliest release",
+			[]trackVersion{
+				{isrc: "A", popularity: 90, releaseDate: "2004-03-01", playable: true},
+				{isrc: "A", popularity: 10, releaseDate: "1977-02-04", playable: true},
+			},
+			DedupeEarliest,
+			[]int{1},
+		},
+		{
+			"earliest prefers a known date",
+			[]trackVersion{
+				{isrc: "A", popularity: 90, releaseDate: "", playable: true},
+				{isrc: "A", popularity: 10, releaseDate: "2004", playable: true},
+			},
+			DedupeEarliest,
+			[]int{1},
+		},
+		{
+			"album version",
+			[]trackVersion{
+				{isrc: "A", popularity: 90, albumType: "compilation", playable: true},
+				{isrc: "A", popularity: 10, albumType: "album", playable: true},
+			},
+			DedupePreferAlbum,
+			[]int{1},
+		},
+		{
+			"kept in first-seen order",
+			[]trackVersion{
+				{isrc: "A", popularity: 10, playable: true},
+				{isrc: "B", popularity: 10, playable: true},
+				{isrc: "A", popularity: 90, playable: true},
+			},
+			DedupeMostPopular,
+			[]int{2, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := dedupeVersions(tt.versions, tt.rule)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dedupeVersions(%q) = %v; want %v", tt.rule, got, tt.want)
+			}
+		})
+	}
+}
+
+func fullTrack(id, name, artist string, popularity int) spotify.FullTrack {
+	return spotify.FullTrack{
+		SimpleTrack: spotify.SimpleTrack{
+			ID:      spotify.ID(id),
+			Name:    name,
+			Artists: []spotify.SimpleArtist{{Name: artist}},
+		},
+		Popularity: spotify.Numeric(popularity),
+	}
+}
+
+func TestDedupeTracks(t *testing.T) {
+	tracks := []spotify.FullTrack{
+		fullTrack("1", "Dreams", "Fleetwood Mac", 60),
+		fullTrack("2", "Dreams - 2004 Remaster", "Fleetwood Mac", 80),
+		fullTrack("3", "Dreams (Live)", "Fleetwood Mac", 20),
+		fullTrack("4", "Dreams (Deep House Remix)", "Fleetwood Mac", 90),
+		fullTrack("5", "Dreams - Extended Mix", "Fleetwood Mac", 10),
+		fullTrack("6", "Rhiannon", "Fleetwood Mac", 50),
+	}
+
+	tests := []struct {
+		name    string
+		rule    string
+		wantIDs []string
+		dropped int
+	}{
+		{"empty rule keeps everything", "", []string{"1", "2", "3", "4", "5", "6"}, 0},
+		{"none keeps everything", DedupeNone, []string{"1", "2", "3", "4", "5", "6"}, 0},
+		{"most popular, mixes kept", DedupeMostPopular, []string{"2", "4", "5", "6"}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, dropped := DedupeTracks(tracks, tt.rule)
+			ids := make([]string, len(got))
+			for i, track := range got {
+				ids[i] = track.ID.String()
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) || dropped != tt.dropped {
+				t.Errorf("DedupeTracks(%q) = %v, %d dropped; want %v, %d dropped", tt.rule, ids, dropped, tt.wantIDs, tt.dropped)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/go-analyze/charts"
+	"github.com/zmb3/spotify/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	GenreWeightArtists = "artists"
+	GenreWeightTracks  = "tracks"
+
+	GenreChartPie   = "pie"
+	GenreChartDonut = "donut"
+	GenreChartBar   = "bar"
+
+	defaultGenreTopN = 10
+	maxGenreTopN     = 50
+	otherGenres      = "other"
+)
+
+// GenreOptions controls how genres are counted and drawn. Zero values use the defaults.
+type GenreOptions struct {
+	Weight string // artists (default) counts each artist once, tracks counts each track
+	TopN   int    // genres shown before the rest are folded into "other", 10 by default
+	Chart  string // pie (default), donut or bar
+}
+
+// GenreData godoc
+// @Description How the artists or tracks in a library split across genres. Genres past the top N are summed into a final "other" entry.
+// @name GenreData
+type GenreData struct {
+	Title       string    `json:"title"`
+	Weight      string    `json:"weight" example:"artists"` // what each genre's value counts: artists or tracks
+	Labels      []string  `json:"labels"`                   // genres, largest first, with "other" last
+	Values      []float64 `json:"values"`
+	OtherGenres int       `json:"other_genres"` // genres folded into "other"
+	NoGenre     int       `json:"no_genre"`     // artists or tracks Spotify has no genres for, left out
+}
+
+func (o GenreOptions) Validate() error {
+	switch o.Weight {
+	case "", GenreWeightArtists, GenreWeightTracks:
+	default:
+		return fmt.Errorf("unknown weight %q, expected artists or tracks", o.Weight)
+	}
+	switch o.Chart {
+	case "", GenreChartPie, GenreChartDonut, GenreChartBar:
+	default:
+		return fmt.Errorf("unknown chart %q, expected pie, donut or bar", o.Chart)
+	}
+	if o.TopN < 0 || o.TopN > maxGenreTopN {
+		return fmt.Errorf("top must be between 1 and %d", maxGenreTopN)
+	}
+	return nil
+}
+
+func (o GenreOptions) weight() string {
+	if o.Weight == "" {
+		return GenreWeightArtists
+	}
+	return o.Weight
+}
+
+func (o GenreOptions) topN() int {
+	if o.TopN == 0 {
+		return defaultGenreTopN
+	}
+	return o.TopN
+}
+
+// GetTrackArtists looks up every artist credited on the tracks, for their genres
+func GetTrackArtists(ctx context.Context, client *spotify.Client, tracks []spotify.FullTrack) (map[spotify.ID]*spotify.FullArtist, error) {
+	var ids []spotify.ID
+	seen := map[spotify.ID]bool{}
+	for _, t := range tracks {
+		for _, a := range t.Artists {
+			if a.ID == "" || seen[a.ID] {
+				continue
+			}
+			seen[a.ID] = true
+			ids = append(ids, a.ID)
+		}
+	}
+
+	artists := make(map[spotify.ID]*spotify.FullArtist, len(ids))
+	for start := 0; start < len(ids); start += artistLookupBatchSize {
+		batch := ids[start:min(start+artistLookupBatchSize, len(ids))]
+		found, err := client.GetArtists(ctx, batch...)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range found {
+			if a != nil {
+				artists[a.ID] = a
+			}
+		}
+	}
+
+	zap.L().Info("Looked up track artists", zap.Int("count", len(artists)))
+	return artists, nil
+}
+
+// AggregateGenres counts the genres of the tracks' artists. Weighted by artists, each
+// artist counts once towards each of their genres; weighted by tracks, each track counts
+// once towards each genre of any artist on it.
+func AggregateGenres(
+	tracks []spotify.FullTrack,
+	artists map[spotify.ID]*spotify.FullArtist,
+	graphTitle string,
+	opts GenreOptions,
+) GenreData {
+	data := GenreData{Title: graphTitle, Weight: opts.weight(), Labels: []string{}, Values: []float64{}}
+	counts := map[string]float64{}
+
+	if data.Weight == GenreWeightTracks {
+		for _, t := range tracks {
+			genres := map[string]bool{}
+			for _, a := range t.Artists {
+				if artist := artists[a.ID]; artist != nil {
+					for _, g := range artist.Genres {
+						genres[g] = true
+					}
+				}
+			}
+			if len(genres) == 0 {
+				data.NoGenre++
+			}
+			for g := range genres {
+				counts[g]++
+			}
+		}
+	} else {
+		seen := map[spotify.ID]bool{}
+		for _, t := range tracks {
+			for _, a := range t.Artists {
+				if a.ID == "" || seen[a.ID] {
+					continue
+				}
+				seen[a.ID] = true
+				artist := artists[a.ID]
+				if artist == nil || len(artist.Genres) == 0 {
+					data.NoGenre++
+					continue
+				}
+				for _, g := range artist.Genres {
+					counts[g]++
+				}
+			}
+		}
+	}
+
+	genres := make([]string, 0, len(counts))
+	for g := range counts {
+		genres = append(genres, g)
+	}
+	sort.Slice(genres, func(i, j int) bool {
+		if counts[genres[i]] != counts[genres[j]] {
+			return counts[genres[i]] > counts[genres[j]]
+		}
+		return genres[i] < genres[j]
+	})
+
+	other := 0.0
+	for i, g := range genres {
+		if i < opts.topN() {
+			data.Labels = append(data.Labels, g)
+			data.Values = append(data.Values, counts[g])
+			continue
+		}
+		other += counts[g]
+		data.OtherGenres++
+	}
+	if data.OtherGenres > 0 {
+		data.Labels = append(data.Labels, otherGenres)
+		data.Values = append(data.Values, other)
+	}
+	return data
+}
+
+// RenderGenres draws genre data as a pie, donut or horizontal bar chart
+func RenderGenres(data GenreData, style string, chartOpts ChartOptions) ([]byte, error) {
+	if len(data.Values) == 0 {
+		return nil, fmt.Errorf("no genres found")
+	}
+
+	painter := charts.NewPainter(chartOpts.painterOptions())
+	var err error
+	switch style {
+	case GenreChartBar:
+		// Bars are drawn bottom up, so reverse them to put the largest genre at the top
+		values := make([]float64, len(data.Values))
+		labels := make([]string, len(data.Labels))
+		for i := range data.Values {
+			values[len(values)-1-i] = data.Values[i]
+			labels[len(labels)-1-i] = data.Labels[i]
+		}
+
+		opt := charts.NewHorizontalBarChartOptionWithData([][]float64{values})
+		opt.Theme = chartOpts.theme()
+		opt.Title.Text = data.Title
+		opt.Title.FontStyle = chartOpts.font(chartTitleFontScale)
+		opt.YAxis.Labels = labels
+		opt.YAxis.LabelFontStyle = chartOpts.font(1)
+		opt.XAxis.LabelFontStyle = chartOpts.font(1)
+		opt.SeriesList[0].Label.Show = charts.Ptr(true)
+		opt.SeriesList[0].Label.FontStyle = chartOpts.font(1)
+		err = painter.HorizontalBarChart(opt)
+
+	case GenreChartDonut:
+		opt := charts.NewDoughnutChartOptionWithData(data.Values)
+		opt.Theme = chartOpts.theme()
+		opt.Title.Text = data.Title
+		opt.Title.FontStyle = chartOpts.font(chartTitleFontScale)
+		opt.Legend.Show = charts.Ptr(false)
+		for i := range opt.SeriesList {
+			opt.SeriesList[i].Name = data.Labels[i]
+			opt.SeriesList[i].Label.FontStyle = chartOpts.font(1)
+		}
+		err = painter.DoughnutChart(opt)
+
+	default:
+		opt := charts.NewPieChartOptionWithData(data.Values)
+		opt.Theme = chartOpts.theme()
+		opt.Title.Text = data.Title
+		opt.Title.FontStyle = chartOpts.font(chartTitleFontScale)
+		opt.Legend.Show = charts.Ptr(false)
+		for i := range opt.SeriesList {
+			opt.SeriesList[i].Name = data.Labels[i]
+			opt.SeriesList[i].Label.FontStyle = chartOpts.font(1)
+		}
+		err = painter.PieChart(opt)
+	}
+	if err != nil {
+		zap.L().Error("Failed to build genre chart", zap.Error(err))
+		return nil, err
+	}
+
+	return painter.Bytes()
+}
@@ -1,7 +1,6 @@
 package services
 
 import (
-	"context"
 	"fmt"
 	"math"
 	"sort"
@@ -12,71 +11,194 @@ import (
 	"go.uber.org/zap"
 )
 
-func BarChartTracksByYear(
-	ctx context.Context,
-	client *spotify.Client,
-	tracks []spotify.FullTrack,
-	graphTitle string,
-	trackCountUnit float64,
-) ([]byte, error) {
+// CountTracksByYear returns the number of tracks released in each year
+func CountTracksByYear(tracks []spotify.FullTrack) map[int]float64 {
 	counts := map[int]float64{}
 	for _, t := range tracks {
-		rd := t.Album.ReleaseDate
-		if rd == "" || rd == "0" {
+		releaseDate, ok := TrackReleaseDate(t)
+		if !ok {
 			continue
 		}
+		counts[releaseDate.Year]++
+	}
+	return counts
+}
 
-		year, err := strconv.Atoi(rd[:4])
-		if err != nil || year == 0 {
-			continue
-		}
+const (
+	YearGroupYear   = "year"
+	YearGroupDecade = "decade"
+)
+
+// YearBarOptions controls the bars of a tracks-by-year chart. Zero values use the defaults.
+type YearBarOptions struct {
+	GroupBy string  // year (default) or decade
+	YUnit   float64 // gap between Y axis labels, in tracks
+	XUnit   float64 // gap between X axis labels, in bars; 5 for years, 1 for decades by default
+	Percent bool    // count each series as a percentage of its tracks
+}
 
-		counts[year]++
+func (o YearBarOptions) Validate() error {
+	switch o.GroupBy {
+	case "", YearGroupYear, YearGroupDecade:
+	default:
+		return fmt.Errorf("unknown group_by %q, expected year or decade", o.GroupBy)
+	}
+	if o.YUnit < 0 || o.XUnit < 0 {
+		return fmt.Errorf("y_unit and x_unit can't be negative")
 	}
+	return nil
+}
 
-	// Make sure our list of years starts at the beginning of a decade
-	years := make([]int, 0, len(counts))
-	for y := range counts {
-		years = append(years, y)
+// HeatmapOptions sets the size of the heatmap's cells. Zero values use the defaults.
+type HeatmapOptions struct {
+	YearBucketSize       int // years per column, 3 by default
+	PopularityBucketSize int // popularity points per row, 10 by default
+}
+
+func (o HeatmapOptions) Validate() error {
+	if o.YearBucketSize < 0 || o.YearBucketSize > 50 {
+		return fmt.Errorf("year_bucket must be between 1 and 50")
 	}
+	if o.PopularityBucketSize < 0 || o.PopularityBucketSize > 100 {
+		return fmt.Errorf("popularity_bucket must be between 1 and 100")
+	}
+	return nil
+}
+
+// YearTrackSet is a named set of tracks to count by release year, drawn as one series
+type YearTrackSet struct {
+	Name   string
+	Tracks []spotify.FullTrack
+}
+
+// AggregateTracksByYear counts the tracks released in each year, or decade, from the start
+// of the first decade with any
+func AggregateTracksByYear(tracks []spotify.FullTrack, graphTitle string, bars YearBarOptions) (BarChartData, error) {
+	return AggregateTrackSetsByYear([]YearTrackSet{{Name: "Tracks", Tracks: tracks}}, graphTitle, bars)
+}
+
+// AggregateTrackSetsByYear counts each set's tracks by release year or decade as its own
+// series, over the years from the start of the first decade any set has tracks in. With
+// bars.Percent each series is a percentage of that set's tracks, so sets of different sizes
+// compare.
+func AggregateTrackSetsByYear(sets []YearTrackSet, graphTitle string, bars YearBarOptions) (BarChartData, error) {
+	counts := make([]map[int]float64, len(sets))
+	years := []int{}
+	for i, set := range sets {
+		counts[i] = CountTracksByYear(set.Tracks)
+		for y := range counts[i] {
+			years = append(years, y)
+		}
+	}
+	if len(years) == 0 {
+		return BarChartData{}, fmt.Errorf("no valid release years found")
+	}
+
+	// Make sure our list of years starts at the beginning of a decade
 	sort.Ints(years)
 	minYear := years[0]
 	maxYear := years[len(years)-1]
 	firstDecade := minYear - (minYear % 10)
-	fullYears := []int{}
-	for y := firstDecade; y <= maxYear; y++ {
-		fullYears = append(fullYears, y)
-	}
 
-	values := make([][]float64, 1)
-	values[0] = []float64{}
-	xLabels := []string{}
+	data := BarChartData{
+		Title:  graphTitle,
+		XTitle: "Release Year",
+		Series: make([]ChartSeries, len(sets)),
+	}
+	if bars.Percent {
+		data.YTitle = "% of Tracks"
+	}
+	for i, set := range sets {
+		data.Series[i] = ChartSeries{Name: set.Name, Values: []float64{}}
+	}
 
-	for _, y := range fullYears {
-		xLabels = append(xLabels, strconv.Itoa(y))
+	step := 1
+	if bars.GroupBy == YearGroupDecade {
+		data.XTitle = "Release Decade"
+		step = 10
+	}
+	for start := firstDecade; start <= maxYear; start += step {
+		label := strconv.Itoa(start)
+		if step == 10 {
+			label += "s"
+		}
+		data.Labels = append(data.Labels, label)
+		data.Buckets = append(data.Buckets, ChartBucket{Label: label, Start: start, End: start + step - 1})
+		for i := range sets {
+			// Missing years count as 0
+			count := 0.0
+			for y := start; y < start+step; y++ {
+				count += counts[i][y]
+			}
+			data.Series[i].Values = append(data.Series[i].Values, count)
+		}
+	}
 
-		// Use actual count if present, otherwise 0
-		if count, ok := counts[y]; ok {
-			values[0] = append(values[0], count)
-		} else {
-			values[0] = append(values[0], 0)
+	if bars.Percent {
+		for i := range data.Series {
+			total := 0.0
+			for _, v := range data.Series[i].Values {
+				total += v
+			}
+			if total == 0 {
+				continue
+			}
+			for j, v := range data.Series[i].Values {
+				data.Series[i].Values[j] = v / total * 100
+			}
 		}
 	}
+	return data, nil
+}
 
-	opt := charts.NewBarChartOptionWithData(values)
+// RenderTracksByYear draws tracks-by-year data as a bar chart, with a legend when there's
+// more than one series
+func RenderTracksByYear(data BarChartData, bars YearBarOptions, chartOpts ChartOptions) ([]byte, error) {
+	values := make([][]float64, len(data.Series))
+	names := make([]string, len(data.Series))
+	for i, series := range data.Series {
+		values[i] = series.Values
+		names[i] = series.Name
+	}
 
-	opt.XAxis.Labels = xLabels
-	opt.XAxis.Title = "Release Year"
-	opt.XAxis.Unit = 5
+	xUnit := 5.0
+	if bars.GroupBy == YearGroupDecade {
+		xUnit = 1
+	}
+	if bars.XUnit != 0 {
+		xUnit = bars.XUnit
+	}
 
-	opt.Title.Text = graphTitle
-	opt.YAxis[0].Unit = trackCountUnit
+	opt := charts.NewBarChartOptionWithData(values)
+	opt.Theme = chartOpts.theme()
+
+	opt.XAxis.Labels = data.Labels
+	opt.XAxis.Title = data.XTitle
+	opt.XAxis.Unit = xUnit
+	opt.XAxis.LabelFontStyle = chartOpts.font(1)
+	opt.XAxis.TitleFontStyle = chartOpts.font(1)
+
+	opt.Title.Text = data.Title
+	opt.Title.FontStyle = chartOpts.font(chartTitleFontScale)
+	opt.YAxis[0].Title = data.YTitle
+	opt.YAxis[0].Unit = bars.YUnit
+	opt.YAxis[0].Min = charts.Ptr(0.0)
+	opt.YAxis[0].LabelFontStyle = chartOpts.font(1)
+	opt.YAxis[0].TitleFontStyle = chartOpts.font(1)
+	if bars.Percent {
+		opt.YAxis[0].ValueFormatter = func(v float64) string {
+			return strconv.FormatFloat(v, 'f', -1, 64) + "%"
+		}
+	}
+
+	if len(names) > 1 {
+		opt.Legend.SeriesNames = names
+		opt.Legend.Offset = charts.OffsetRight
+		opt.Legend.FontStyle = chartOpts.font(1)
+	}
 
 	// Render chart
-	painter := charts.NewPainter(charts.PainterOptions{
-		Width:  1280,
-		Height: 720,
-	})
+	painter := charts.NewPainter(chartOpts.painterOptions())
 	if err := painter.BarChart(opt); err != nil {
 		zap.L().Error("Failed to build chart", zap.Error(err))
 		return nil, err
@@ -85,94 +207,83 @@ func BarChartTracksByYear(
 	return painter.Bytes()
 }
 
-func HeatmapTracksByYearAndPopularity(
-	ctx context.Context,
-	client *spotify.Client,
-	tracks []spotify.FullTrack,
-	graphTitle string,
-	yearBucketSize int, // e.g. 3
-	popularityBucketSize int, // e.g. 10
-) ([]byte, error) {
-
-	type cell struct {
-		x int
-		y int
+// AggregateTracksByYearAndPopularity counts the tracks in each release year and Spotify
+// popularity bucket
+func AggregateTracksByYearAndPopularity(tracks []spotify.FullTrack, graphTitle string, buckets HeatmapOptions) (HeatmapData, error) {
+	yearBucketSize := buckets.YearBucketSize
+	if yearBucketSize == 0 {
+		yearBucketSize = 3
+	}
+	popularityBucketSize := buckets.PopularityBucketSize
+	if popularityBucketSize == 0 {
+		popularityBucketSize = 10
 	}
 
-	// --- Collect valid years and popularity ---
+	// --- Collect valid years ---
 	years := []int{}
 	for _, t := range tracks {
-		if t.Album.ReleaseDate == "" {
-			continue
-		}
-		year, err := strconv.Atoi(t.Album.ReleaseDate[:4])
-		if err != nil || year == 0 {
+		releaseDate, ok := TrackReleaseDate(t)
+		if !ok {
 			continue
 		}
-		years = append(years, year)
+		years = append(years, releaseDate.Year)
 	}
 
 	if len(years) == 0 {
-		return nil, fmt.Errorf("no valid release years found")
+		return HeatmapData{}, fmt.Errorf("no valid release years found")
 	}
 
 	sort.Ints(years)
 	minYear := years[0]
 	maxYear := years[len(years)-1]
 
+	data := HeatmapData{
+		Title:  graphTitle,
+		XTitle: "Release Year",
+		YTitle: "Popularity on Spotify",
+	}
+
 	// --- Build year buckets ---
 	bucketCount := int(math.Ceil(float64(maxYear-minYear+1) / float64(yearBucketSize)))
-	xLabels := make([]string, bucketCount)
-	bucketStartYears := make([]int, bucketCount)
-
 	for i := 0; i < bucketCount; i++ {
 		start := minYear + i*yearBucketSize
 		end := start + yearBucketSize - 1
 
-		startCentury := start / 100
-		endCentury := end / 100
-
-		if startCentury == endCentury {
+		var label string
+		if start/100 == end/100 {
 			// Same century, safe to shorten last two digits
-			xLabels[i] = fmt.Sprintf("%d-%02d", start, end%100)
+			label = fmt.Sprintf("%d-%02d", start, end%100)
 		} else {
 			// Different centuries, show full years
-			xLabels[i] = fmt.Sprintf("%d-%d", start, end)
+			label = fmt.Sprintf("%d-%d", start, end)
 		}
-		bucketStartYears[i] = start
-	}
-
-	yearBucketIndex := map[int]int{}
-	for i, start := range bucketStartYears {
-		yearBucketIndex[start] = i
+		data.XLabels = append(data.XLabels, label)
+		data.XBuckets = append(data.XBuckets, ChartBucket{Label: label, Start: start, End: end})
 	}
 
 	// --- Build popularity buckets ---
 	popBucketCount := int(math.Ceil(101.0 / float64(popularityBucketSize)))
-	yLabels := make([]string, popBucketCount)
 	for j := 0; j < popBucketCount; j++ {
 		start := j * popularityBucketSize
-		end := start + popularityBucketSize - 1
-		if end > 100 {
-			end = 100
-		}
-		yLabels[j] = fmt.Sprintf("%d-%d", start, end)
+		end := min(start+popularityBucketSize-1, 100)
+		label := fmt.Sprintf("%d-%d", start, end)
+		data.YLabels = append(data.YLabels, label)
+		data.YBuckets = append(data.YBuckets, ChartBucket{Label: label, Start: start, End: end})
 	}
 
 	// --- Count cells ---
-	counts := map[cell]float64{}
+	data.Values = make([][]float64, popBucketCount)
+	for y := range data.Values {
+		data.Values[y] = make([]float64, bucketCount)
+	}
 	for _, t := range tracks {
-		if t.Album.ReleaseDate == "" {
+		releaseDate, ok := TrackReleaseDate(t)
+		if !ok {
 			continue
 		}
-		year, err := strconv.Atoi(t.Album.ReleaseDate[:4])
-		if err != nil {
-			continue
-		}
-		pop := int(t.Popularity)
 
-		x := (year - minYear) / yearBucketSize
-		y := pop / popularityBucketSize
+		x := (releaseDate.Year - minYear) / yearBucketSize
+		y := int(t.Popularity) / popularityBucketSize
 
 		if x < 0 || x >= bucketCount {
 			continue
@@ -181,33 +292,37 @@ func HeatmapTracksByYearAndPopularity(
 			continue
 		}
 
-		counts[cell{x, y}]++
+		data.Values[y][x]++
 	}
 
-	// --- Convert to heatmap matrix ---
-	values := make([][]float64, popBucketCount)
-	for y := 0; y < popBucketCount; y++ {
-		values[y] = make([]float64, bucketCount)
-	}
+	return data, nil
+}
 
-	for c, v := range counts {
-		values[c.y][c.x] = v
+// RenderHeatmap draws heatmap data with the lowest y bucket at the bottom
+func RenderHeatmap(data HeatmapData, chartOpts ChartOptions) ([]byte, error) {
+	// The first row is drawn at the top, so the highest bucket goes first
+	values := make([][]float64, len(data.Values))
+	for y, row := range data.Values {
+		values[len(values)-1-y] = row
 	}
 
-	// --- Build chart ---
 	opt := charts.NewHeatMapOptionWithData(values)
-	opt.Title.Text = graphTitle
-	opt.XAxis.Labels = xLabels
-	opt.XAxis.Title = "Release Year"
+	opt.Theme = chartOpts.theme()
+	opt.Title.Text = data.Title
+	opt.XAxis.Labels = data.XLabels
+	opt.XAxis.Title = data.XTitle
 	opt.XAxis.LabelRotation = math.Pi / 2
-	opt.YAxis.Labels = yLabels
-	opt.YAxis.Title = "Popularity on Spotify"
+	opt.YAxis.Labels = data.YLabels
+	opt.YAxis.LabelCount = len(data.YLabels)
+	opt.YAxis.Title = data.YTitle
+	opt.Title.FontStyle = chartOpts.font(chartTitleFontScale)
+	opt.XAxis.LabelFontStyle = chartOpts.font(1)
+	opt.XAxis.TitleFontStyle = chartOpts.font(1)
+	opt.YAxis.LabelFontStyle = chartOpts.font(1)
+	opt.YAxis.TitleFontStyle = chartOpts.font(1)
 
 	// --- Render ---
-	painter := charts.NewPainter(charts.PainterOptions{
-		Width:  1280,
-		Height: 720,
-	})
+	painter := charts.NewPainter(chartOpts.painterOptions())
 
 	if err := painter.HeatMapChart(opt); err != nil {
 		zap.L().Error("Failed to build heatmap", zap.Error(err))
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-analyze/charts"
+	"github.com/zmb3/spotify/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	growthMonthLayout = "2006-01"
+	growthXLabels     = 12
+)
+
+// GrowthSource is when each track on the liked songs or a playlist was added
+type GrowthSource struct {
+	Name       string
+	PlaylistID string // empty for liked songs
+	AddedAt    []time.Time
+	Undated    int // tracks with no added date, which very old playlists don't record
+}
+
+// GrowthSeries godoc
+// @Description How one of the liked songs or a playlist grew, with a value for each month
+// @name GrowthSeries
+type GrowthSeries struct {
+	Name       string    `json:"name" example:"Liked Songs"`
+	PlaylistID string    `json:"playlist_id,omitempty"`
+	Added      []float64 `json:"added"`   // tracks added that month
+	Total      []float64 `json:"total"`   // tracks there by the end of the month
+	Undated    int       `json:"undated"` // tracks with no added date, left out
+}
+
+// LibraryGrowthData godoc
+// @Description How the user's liked songs and playlists grew over time. Only tracks still there are counted, so removed tracks don't show.
+// @name LibraryGrowthData
+type LibraryGrowthData struct {
+	Title  string         `json:"title"`
+	Months []string       `json:"months" example:"2019-03"` // YYYY-MM, from the first month anything was added to the last
+	Series []GrowthSeries `json:"series"`
+}
+
+// LikedSongsGrowthSource reads when each liked song was saved
+func LikedSongsGrowthSource(ctx context.Context, client *spotify.Client) (GrowthSource, error) {
+	saved, err := GetAllUserSavedTrackItems(ctx, client)
+	if err != nil {
+		return GrowthSource{}, err
+	}
+
+	source := GrowthSource{Name: "Liked Songs"}
+	for _, t := range saved {
+		source.add(t.AddedAt)
+	}
+	return source, nil
+}
+
+// PlaylistGrowthSource reads when each track was added to the playlist, skipping episodes
+// and unavailable tracks
+func PlaylistGrowthSource(ctx context.Context, client *spotify.Client, playlist spotify.SimplePlaylist) (GrowthSource, error) {
+	items, err := GetAllPlaylistItems(ctx, client, playlist)
+	if err != nil {
+		return GrowthSource{}, err
+	}
+
+	source := GrowthSource{Name: playlist.Name, PlaylistID: playlist.ID.String()}
+	for _, item := range items {
+		if item.Track.Track == nil {
+			continue
+		}
+		source.add(item.AddedAt)
+	}
+	return source, nil
+}
+
+func (s *GrowthSource) add(addedAt string) {
+	added, err := time.Parse(spotify.TimestampLayout, addedAt)
+	if err != nil {
+		s.Undated++
+		return
+	}
+	s.AddedAt = append(s.AddedAt, added)
+}
+
+// AggregateLibraryGrowth counts the tracks added to each source per month, and the running
+// total, over the months from the first track added to any source to the last
+func AggregateLibraryGrowth(sources []GrowthSource, graphTitle string) (LibraryGrowthData, error) {
+	var first, last time.Time
+	for _, s := range sources {
+		for _, added := range s.AddedAt {
+			month := time.Date(added.Year(), added.Month(), 1, 0, 0, 0, 0, time.UTC)
+			if first.IsZero() || month.Before(first) {
+				first = month
+			}
+			if month.After(last) {
+				last = month
+			}
+		}
+	}
+	if first.IsZero() {
+		return LibraryGrowthData{}, fmt.Errorf("no added dates found")
+	}
+
+	data := LibraryGrowthData{Title: graphTitle, Months: []string{}, Series: []GrowthSeries{}}
+	monthIndex := map[string]int{}
+	for m := first; !m.After(last); m = m.AddDate(0, 1, 0) {
+		monthIndex[m.Format(growthMonthLayout)] = len(data.Months)
+		data.Months = append(data.Months, m.Format(growthMonthLayout))
+	}
+
+	for _, s := range sources {
+		series := GrowthSeries{
+			Name:       s.Name,
+			PlaylistID: s.PlaylistID,
+			Added:      make([]float64, len(data.Months)),
+			Total:      make([]float64, len(data.Months)),
+			Undated:    s.Undated,
+		}
+		for _, added := range s.AddedAt {
+			series.Added[monthIndex[added.UTC().Format(growthMonthLayout)]]++
+		}
+		total := 0.0
+		for i, added := range series.Added {
+			total += added
+			series.Total[i] = total
+		}
+		data.Series = append(data.Series, series)
+	}
+	return data, nil
+}
+
+// RenderLibraryGrowth draws each series' running total as a line, with the tracks added
+// each month across every series as bars against the right axis when showAdded is set
+func RenderLibraryGrowth(data LibraryGrowthData, showAdded bool, chartOpts ChartOptions) ([]byte, error) {
+	seriesList := make(charts.GenericSeriesList, 0, len(data.Series)+1)
+	for _, s := range data.Series {
+		seriesList = append(seriesList, charts.GenericSeries{Type: charts.ChartTypeLine, Name: s.Name, Values: s.Total})
+	}
+
+	yAxis := []charts.YAxisOption{{Title: "Tracks", Min: charts.Ptr(0.0)}}
+	if showAdded {
+		added := make([]float64, len(data.Months))
+		for _, s := range data.Series {
+			for i, v := range s.Added {
+				added[i] += v
+			}
+		}
+		seriesList = append(seriesList, charts.GenericSeries{
+			Type:       charts.ChartTypeBar,
+			Name:       "Added that month",
+			Values:     added,
+			YAxisIndex: 1,
+		})
+		yAxis = append(yAxis, charts.YAxisOption{Title: "Added", Min: charts.Ptr(0.0)})
+	}
+	for i := range yAxis {
+		yAxis[i].LabelFontStyle = chartOpts.font(1)
+		yAxis[i].TitleFontStyle = chartOpts.font(1)
+	}
+
+	painterOpts := chartOpts.painterOptions()
+	opt := charts.ChartOption{
+		OutputFormat: painterOpts.OutputFormat,
+		Width:        painterOpts.Width,
+		Height:       painterOpts.Height,
+		Theme:        chartOpts.theme(),
+		SeriesList:   seriesList,
+		YAxis:        yAxis,
+		Symbol:       charts.SymbolNone,
+	}
+	opt.Title.Text = data.Title
+	opt.Title.FontStyle = chartOpts.font(chartTitleFontScale)
+	opt.XAxis.Labels = growthMonthLabels(data.Months)
+	opt.XAxis.LabelCount = len(data.Months)
+	opt.XAxis.LabelFontStyle = chartOpts.font(1)
+	opt.Legend.Offset = charts.OffsetRight
+	opt.Legend.FontStyle = chartOpts.font(1)
+
+	painter, err := charts.Render(opt)
+	if err != nil {
+		zap.L().Error("Failed to build library growth chart", zap.Error(err))
+		return nil, err
+	}
+
+	return painter.Bytes()
+}
+
+// growthMonthLabels keeps every few months' labels, a step that leaves around a dozen,
+// and blanks the rest. The chart library's own label thinning lets the last labels overlap.
+func growthMonthLabels(months []string) []string {
+	step := 1
+	for _, step = range []int{1, 2, 3, 6, 12, 24, 60, 120} {
+		if len(months)/step <= growthXLabels {
+			break
+		}
+	}
+
+	labels := make([]string, len(months))
+	for i := 0; i < len(months); i += step {
+		labels[i] = months[i]
+	}
+	return labels
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+
+	"github.com/CallumClarke65/spotify-analytics/internal/spotifyauth"
+	"github.com/zmb3/spotify/v2"
+)
+
+// marketOptions asks Spotify for the versions of tracks playable in the user's country.
+// Spotify then swaps in a relinked version where the original isn't available there,
+// noting the original under linked_from, and reports is_playable.
+func marketOptions(ctx context.Context) []spotify.RequestOption {
+	market := spotifyauth.MarketFromContext(ctx)
+	if market == "" {
+		return nil
+	}
+	return []spotify.RequestOption{spotify.Market(market)}
+}
+
+// IsPlayable is false only when Spotify has said the track can't be played in the market
+// it was fetched for
+func IsPlayable(track spotify.FullTrack) bool {
+	return track.IsPlayable == nil || *track.IsPlayable
+}
+
+// RemoveUnplayable drops tracks that can't be played in the user's market, returning how
+// many were dropped
+func RemoveUnplayable(tracks []spotify.FullTrack) ([]spotify.FullTrack, int) {
+	playable := make([]spotify.FullTrack, 0, len(tracks))
+	for _, t := range tracks {
+		if IsPlayable(t) {
+			playable = append(playable, t)
+		}
+	}
+	return playable, len(tracks) - len(playable)
+}
+
+// linkedFromID is the ID of the track that was asked for when Spotify relinked it to a
+// version playable in the market, or empty
+func linkedFromID(track spotify.FullTrack) spotify.ID {
+	if track.LinkedFrom == nil {
+		return ""
+	}
+	return track.LinkedFrom.ID
+}
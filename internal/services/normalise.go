@@ -0,0 +1,74 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Words that mark a suffix as describing the version of a recording rather than the song.
+// Mixes and remixes are left out, as they're usually different enough to keep both.
+const versionWords = `remaster|remastered|version|edit|mono|stereo|live|demo|deluxe|anniversary|single|radio|acoustic|explicit|clean|bonus|instrumental`
+
+var (
+	// e.g. "Song - Remastered 2011", "Song - Live at Wembley", "Song - Radio Edit"
+	dashVersionSuffix = regexp.MustCompile(`(?i)\s+-\s+[^-]*\b(` + versionWords + `)\b.*$`)
+	// e.g. "Song (Live)", "Song [2011 Remaster]"
+	bracketVersionSuffix = regexp.MustCompile(`(?i)\s*[\(\[][^\)\]]*\b(` + versionWords + `)\b[^\)\]]*[\)\]]`)
+	// e.g. "Song (feat. Someone)", "Song - feat. Someone"
+	featuringSuffix = regexp.MustCompile(`(?i)\s*([\(\[]\s*(feat|ft|featuring|with)\b\.?[^\)\]]*[\)\]]|\s-\s(feat|ft|featuring)\b.*$)`)
+	liveVersion     = regexp.MustCompile(`(?i)(\s-\s.*\blive\b|[\(\[][^\)\]]*\blive\b[^\)\]]*[\)\]])`)
+)
+
+// Letters that NFD doesn't split into a base letter and accents
+var letterFolds = map[rune]string{
+	'æ': "ae", 'œ': "oe", 'ø': "o", 'ß': "ss", 'ł': "l", 'đ': "d", 'ı': "i",
+}
+
+// NormaliseName lowercases, strips accents and reduces punctuation to single spaces, so
+// "Beyoncé" and "beyonce" or "AC/DC" and "ac dc" compare equal. Accents are stripped by
+// decomposing each letter (NFD) and dropping the combining marks.
+func NormaliseName(name string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range norm.NFD.String(strings.ToLower(name)) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if folded, ok := letterFolds[r]; ok {
+			b.WriteString(folded)
+			space = false
+			continue
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			space = false
+			continue
+		}
+		// Drop apostrophes so "don't" and "dont" match
+		if r == '\'' || r == '’' {
+			continue
+		}
+		if !space && b.Len() > 0 {
+			b.WriteByte(' ')
+			space = true
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// NormaliseTrackTitle strips version and featuring suffixes before normalising, so
+// "Dreams - 2004 Remaster" and "Dreams (Live)" both become "dreams"
+func NormaliseTrackTitle(title string) string {
+	title = featuringSuffix.ReplaceAllString(title, "")
+	title = dashVersionSuffix.ReplaceAllString(title, "")
+	title = bracketVersionSuffix.ReplaceAllString(title, "")
+	return NormaliseName(title)
+}
+
+// IsLiveVersion reports whether the title marks the track as a live recording
+func IsLiveVersion(title string) bool {
+	return liveVersion.MatchString(title)
+}
@@ -0,0 +1,29 @@
+package services
+
+import "testing"
+
+func TestNormaliseName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Beyoncé", "beyonce"},
+		{"AC/DC", "ac dc"},
+		{"Sigur Rós", "sigur ros"},
+		{"Motörhead", "motorhead"},
+		{"Mötley Crüe", "motley crue"},
+		{"Røyksopp", "royksopp"},
+		{"Łona", "lona"},
+		{"Don't Stop", "dont stop"},
+		{"Ñandú", "nandu"},
+		{"  Guns N' Roses  ", "guns n roses"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormaliseName(tt.name); got != tt.want {
+				t.Errorf("NormaliseName(%q) = %q; want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,468 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	PlaylistActionCreate = "create"
+	PlaylistActionUpdate = "update"
+
+	// Spotify accepts at most 100 tracks per add/remove call
+	playlistBatchSize = 100
+)
+
+var (
+	ErrPlaylistPlanNotFound       = errors.New("playlist plan not found")
+	ErrPlaylistPlanAlreadyApplied = errors.New("playlist plan has already been applied")
+	ErrPlaylistChangedSincePlan   = errors.New("playlist has changed since the plan was created")
+)
+
+var planIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// PlaylistPlan godoc
+// @Description Every change that would be made to the user's Spotify playlists. Returned by dry runs and can be applied later by ID.
+// @name PlaylistPlan
+type PlaylistPlan struct {
+	PlanID    string           `json:"plan_id"`
+	UserID    string           `json:"user_id"`
+	CreatedAt string           `json:"created_at"`
+	AppliedAt string           `json:"applied_at,omitempty"`
+	Playlists []PlaylistChange `json:"playlists"`
+}
+
+// PlaylistChange godoc
+// @Description A single playlist to create or update as part of a PlaylistPlan
+// @name PlaylistChange
+type PlaylistChange struct {
+	Action        string            `json:"action"` // "create" or "update"
+	PlaylistID    string            `json:"playlist_id,omitempty"`
+	SnapshotID    string            `json:"snapshot_id,omitempty"`
+	Name          string            `json:"name"`
+	Description   string            `json:"description"`
+	Public        bool              `json:"public"`
+	Collaborative bool              `json:"collaborative"` // updates keep the playlist's current setting
+	TrackIDs      []string          `json:"track_ids"`     // final track order once applied
+	Add           []string          `json:"add"`
+	Remove        []string          `json:"remove"`
+	Reorder       []PlaylistReorder `json:"reorder"`
+	AddBatches    int               `json:"add_batches"`
+	RemoveBatches int               `json:"remove_batches"`
+	CoverImage    []byte            `json:"cover_image,omitempty" swaggertype:"string" format:"base64"` // JPEG
+	Progress      PlaylistProgress  `json:"progress"`
+	Applied       bool              `json:"applied"`
+}
+
+// PlaylistProgress godoc
+// @Description How much of a PlaylistChange has been made, so an apply that fails part way is retried from where it stopped
+// @name PlaylistProgress
+type PlaylistProgress struct {
+	Details       bool `json:"details"` // playlist created, or renamed for an update
+	RemoveBatches int  `json:"remove_batches"`
+	AddBatches    int  `json:"add_batches"`
+	Moves         int  `json:"moves"`
+	Cover         bool `json:"cover"`
+}
+
+func (p PlaylistProgress) started() bool {
+	return p.Details || p.RemoveBatches > 0 || p.AddBatches > 0 || p.Moves > 0 || p.Cover
+}
+
+// PlaylistReorder godoc
+// @Description Moves one track, applied in order after tracks have been removed and added
+// @name PlaylistReorder
+type PlaylistReorder struct {
+	TrackID string `json:"track_id"`
+	From    int    `json:"from"`
+	To      int    `json:"to"`
+}
+
+// GeneratedPlaylist describes the desired end state of a playlist generated by the service
+type GeneratedPlaylist struct {
+	Name          string
+	Description   string
+	Public        bool
+	Collaborative bool
+	TrackIDs      []string
+	CoverImage    []byte
+}
+
+// NewPlaylistPlan works out the changes needed to make the user's playlists match the
+// generated ones. When replaceExisting is set, a playlist the user owns with the same
+// name is updated in place; otherwise a new playlist is always created.
+func NewPlaylistPlan(
+	ctx context.Context,
+	client *spotify.Client,
+	userID string,
+	generated []GeneratedPlaylist,
+	replaceExisting bool,
+) (*PlaylistPlan, error) {
+
+	planID, err := newPlanID()
+	if err != nil {
+		return nil, err
+	}
+
+	existingByName := map[string]spotify.SimplePlaylist{}
+	if replaceExisting {
+		playlists, err := GetAllUserPlaylists(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range playlists {
+			if p.Owner.ID != userID {
+				continue
+			}
+			if _, ok := existingByName[p.Name]; !ok {
+				existingByName[p.Name] = p
+			}
+		}
+	}
+
+	plan := &PlaylistPlan{
+		PlanID:    planID,
+		UserID:    userID,
+		CreatedAt: time.Now().Format(time.RFC3339),
+		Playlists: make([]PlaylistChange, 0, len(generated)),
+	}
+
+	for _, g := range generated {
+		target := uniqueTrackIDs(g.TrackIDs)
+		change := PlaylistChange{
+			Action:        PlaylistActionCreate,
+			Name:          g.Name,
+			Description:   g.Description,
+			Public:        g.Public,
+			Collaborative: g.Collaborative,
+			TrackIDs:      target,
+			Add:           target,
+			Remove:        []string{},
+			Reorder:       []PlaylistReorder{},
+			CoverImage:    g.CoverImage,
+		}
+
+		if existing, ok := existingByName[g.Name]; ok {
+			items, err := GetAllPlaylistItems(ctx, client, existing)
+			if err != nil {
+				return nil, err
+			}
+			// One entry per item so moves use real playlist positions. Local files,
+			// episodes and unavailable tracks can't be managed through the API, so they're
+			// left where they are.
+			current := make([]string, 0, len(items))
+			for _, item := range items {
+				if item.Track.Track == nil || item.Track.Track.ID == "" {
+					current = append(current, "")
+					continue
+				}
+				// Tracks fetched for the user's market can be relinked versions, but the
+				// playlist still holds the original, which is what removals have to name
+				id := item.Track.Track.ID
+				if linked := linkedFromID(*item.Track.Track); linked != "" {
+					id = linked
+				}
+				current = append(current, id.String())
+			}
+
+			change.Action = PlaylistActionUpdate
+			change.PlaylistID = existing.ID.String()
+			change.SnapshotID = existing.SnapshotID
+			// The API can't change whether an existing playlist is collaborative
+			change.Collaborative = existing.Collaborative
+			change.Add, change.Remove, change.Reorder = diffPlaylistTracks(current, target)
+		}
+
+		change.AddBatches = batchCount(len(change.Add))
+		change.RemoveBatches = batchCount(len(change.Remove))
+		plan.Playlists = append(plan.Playlists, change)
+	}
+
+	return plan, nil
+}
+
+// ApplyPlaylistPlan makes the changes described by the plan. Updated playlists are
+// checked against their planned snapshot first, so nothing is applied if any of them
+// have been edited since the plan was created. The plan is saved after every step, so if
+// applying fails part way a retry carries on from the step that failed rather than
+// creating the playlists again. Callers should hold LockPlaylistPlan.
+func ApplyPlaylistPlan(ctx context.Context, client *spotify.Client, plan *PlaylistPlan) error {
+	if plan.AppliedAt != "" {
+		return ErrPlaylistPlanAlreadyApplied
+	}
+
+	for _, change := range plan.Playlists {
+		// Playlists already worked on have moved past their snapshot because of us
+		if change.Action != PlaylistActionUpdate || change.Progress.started() {
+			continue
+		}
+		current, err := client.GetPlaylist(ctx, spotify.ID(change.PlaylistID))
+		if err != nil {
+			return err
+		}
+		if current.SnapshotID != change.SnapshotID {
+			return fmt.Errorf("%w: %s", ErrPlaylistChangedSincePlan, change.Name)
+		}
+	}
+
+	save := func() error {
+		if err := SavePlaylistPlan(plan); err != nil {
+			return fmt.Errorf("failed to save plan progress: %w", err)
+		}
+		return nil
+	}
+
+	for i := range plan.Playlists {
+		change := &plan.Playlists[i]
+		if change.Applied {
+			continue
+		}
+		progress := &change.Progress
+
+		if !progress.Details {
+			switch change.Action {
+			case PlaylistActionCreate:
+				created, err := client.CreatePlaylistForUser(
+					ctx, plan.UserID,
+					change.Name,
+					change.Description,
+					change.Public,
+					change.Collaborative,
+				)
+				if err != nil {
+					return fmt.Errorf("failed to create playlist %s: %w", change.Name, err)
+				}
+				change.PlaylistID = created.ID.String()
+
+			case PlaylistActionUpdate:
+				err := client.ChangePlaylistNameAccessAndDescription(
+					ctx, spotify.ID(change.PlaylistID),
+					change.Name,
+					change.Description,
+					change.Public,
+				)
+				if err != nil {
+					return fmt.Errorf("failed to update playlist %s: %w", change.Name, err)
+				}
+
+			default:
+				return fmt.Errorf("unknown playlist action %q", change.Action)
+			}
+			progress.Details = true
+			if err := save(); err != nil {
+				return err
+			}
+		}
+
+		for _, batch := range trackIDBatches(change.Remove)[progress.RemoveBatches:] {
+			if _, err := client.RemoveTracksFromPlaylist(ctx, spotify.ID(change.PlaylistID), batch...); err != nil {
+				return fmt.Errorf("failed to remove tracks from playlist %s: %w", change.Name, err)
+			}
+			progress.RemoveBatches++
+			if err := save(); err != nil {
+				return err
+			}
+		}
+
+		for _, batch := range trackIDBatches(change.Add)[progress.AddBatches:] {
+			if _, err := client.AddTracksToPlaylist(ctx, spotify.ID(change.PlaylistID), batch...); err != nil {
+				return fmt.Errorf("failed to add tracks to playlist %s: %w", change.Name, err)
+			}
+			progress.AddBatches++
+			if err := save(); err != nil {
+				return err
+			}
+		}
+
+		for _, move := range change.Reorder[progress.Moves:] {
+			_, err := client.ReorderPlaylistTracks(ctx, spotify.ID(change.PlaylistID), spotify.PlaylistReorderOptions{
+				RangeStart:   spotify.Numeric(move.From),
+				InsertBefore: spotify.Numeric(move.To),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to reorder playlist %s: %w", change.Name, err)
+			}
+			progress.Moves++
+			if err := save(); err != nil {
+				return err
+			}
+		}
+
+		if len(change.CoverImage) > 0 && !progress.Cover {
+			if err := client.SetPlaylistImage(ctx, spotify.ID(change.PlaylistID), bytes.NewReader(change.CoverImage)); err != nil {
+				return fmt.Errorf("failed to upload cover for playlist %s: %w", change.Name, err)
+			}
+			progress.Cover = true
+		}
+
+		change.Applied = true
+		if err := save(); err != nil {
+			return err
+		}
+
+		zap.L().Info("Applied playlist change",
+			zap.String("action", change.Action),
+			zap.String("playlist_id", change.PlaylistID),
+			zap.Int("added", len(change.Add)),
+			zap.Int("removed", len(change.Remove)),
+			zap.Int("moved", len(change.Reorder)),
+		)
+	}
+
+	plan.AppliedAt = time.Now().Format(time.RFC3339)
+	return save()
+}
+
+var (
+	// Guards playlistPlanLocks; each plan's own lock is held for a whole apply
+	playlistPlanLocksLock sync.Mutex
+	playlistPlanLocks     = map[string]*sync.Mutex{}
+)
+
+// LockPlaylistPlan stops the plan being applied twice at once. Load the plan after taking
+// the lock so a finished apply is seen, and call the returned func to release it.
+func LockPlaylistPlan(planID string) func() {
+	playlistPlanLocksLock.Lock()
+	lock, ok := playlistPlanLocks[planID]
+	if !ok {
+		lock = &sync.Mutex{}
+		playlistPlanLocks[planID] = lock
+	}
+	playlistPlanLocksLock.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+func SavePlaylistPlan(plan *PlaylistPlan) error {
+	if plan.UserID == "" {
+		return ErrNoUserID
+	}
+	return WriteJsonObjectToFile(plan, "playlist_plan_"+plan.PlanID)
+}
+
+func LoadPlaylistPlan(planID string) (*PlaylistPlan, error) {
+	if !planIDPattern.MatchString(planID) {
+		return nil, ErrPlaylistPlanNotFound
+	}
+
+	var plan PlaylistPlan
+	// A plan without an owner could be applied by anyone, so treat it as missing
+	if err := ReadJsonObjectFromFile(&plan, "playlist_plan_"+planID); err != nil || plan.UserID == "" {
+		return nil, ErrPlaylistPlanNotFound
+	}
+	return &plan, nil
+}
+
+// diffPlaylistTracks returns the removals, additions and moves (in that order of
+// application) that turn current into target. current has an entry for every item in
+// the playlist, "" for items that can't be managed; those stay in the playlist and end up
+// after the target tracks. Tracks that appear more than once are removed and re-added so
+// the result has no duplicates.
+func diffPlaylistTracks(current, target []string) (add, remove []string, reorder []PlaylistReorder) {
+	inTarget := make(map[string]bool, len(target))
+	for _, id := range target {
+		inTarget[id] = true
+	}
+	occurrences := make(map[string]int, len(current))
+	for _, id := range current {
+		occurrences[id]++
+	}
+
+	remove = []string{}
+	removed := map[string]bool{}
+	for _, id := range current {
+		if id == "" || removed[id] {
+			continue
+		}
+		if !inTarget[id] || occurrences[id] > 1 {
+			remove = append(remove, id)
+			removed[id] = true
+		}
+	}
+
+	simulated := make([]string, 0, len(current)+len(target))
+	kept := map[string]bool{}
+	for _, id := range current {
+		if !removed[id] {
+			simulated = append(simulated, id)
+			kept[id] = true
+		}
+	}
+
+	add = []string{}
+	for _, id := range target {
+		if !kept[id] {
+			add = append(add, id)
+			simulated = append(simulated, id)
+		}
+	}
+
+	reorder = []PlaylistReorder{}
+	for i, id := range target {
+		if simulated[i] == id {
+			continue
+		}
+		j := i + 1
+		for simulated[j] != id {
+			j++
+		}
+		reorder = append(reorder, PlaylistReorder{TrackID: id, From: j, To: i})
+
+		copy(simulated[i+1:j+1], simulated[i:j])
+		simulated[i] = id
+	}
+
+	return add, remove, reorder
+}
+
+func uniqueTrackIDs(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+	}
+	return result
+}
+
+func trackIDBatches(ids []string) [][]spotify.ID {
+	var batches [][]spotify.ID
+	for i := 0; i < len(ids); i += playlistBatchSize {
+		end := i + playlistBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := make([]spotify.ID, 0, end-i)
+		for _, id := range ids[i:end] {
+			batch = append(batch, spotify.ID(id))
+		}
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+func batchCount(n int) int {
+	return (n + playlistBatchSize - 1) / playlistBatchSize
+}
+
+func newPlanID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+// applyPlaylistDiff makes the changes the way Spotify does: removals drop every copy of
+// the track, additions go on the end and moves use positions in the playlist as it is
+func applyPlaylistDiff(current, add, remove []string, reorder []PlaylistReorder) []string {
+	removed := map[string]bool{}
+	for _, id := range remove {
+		removed[id] = true
+	}
+	playlist := []string{}
+	for _, id := range current {
+		if id == "" || !removed[id] {
+			playlist = append(playlist, id)
+		}
+	}
+	playlist = append(playlist, add...)
+
+	for _, move := range reorder {
+		id := playlist[move.From]
+		playlist = append(playlist[:move.From], playlist[move.From+1:]...)
+		playlist = append(playlist[:move.To], append([]string{id}, playlist[move.To:]...)...)
+	}
+	return playlist
+}
+
+func TestDiffPlaylistTracks(t *testing.T) {
+	tests := []struct {
+		name    string
+		current []string
+		target  []string
+		want    []string
+	}{
+		{"empty playlist", nil, []string{"a", "b"}, []string{"a", "b"}},
+		{"unchanged", []string{"a", "b", "c"}, []string{"a", "b", "c"}, []string{"a", "b", "c"}},
+		{"reversed", []string{"a", "b", "c"}, []string{"c", "b", "a"}, []string{"c", "b", "a"}},
+		{"added and removed", []string{"a", "x", "b"}, []string{"b", "c", "a"}, []string{"b", "c", "a"}},
+		{"duplicates", []string{"a", "b", "a"}, []string{"a", "b"}, []string{"a", "b"}},
+		{"local file first", []string{"", "a", "b"}, []string{"b", "a"}, []string{"b", "a", ""}},
+		{"unmanaged items between", []string{"a", "", "b", "", "c"}, []string{"c", "a", "b"}, []string{"c", "a", "b", "", ""}},
+		{"only unmanaged items", []string{"", ""}, []string{"a"}, []string{"a", "", ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			add, remove, reorder := diffPlaylistTracks(tt.current, tt.target)
+			got := applyPlaylistDiff(tt.current, add, remove, reorder)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffPlaylistTracks(%q, %q) gives %q; want %q", tt.current, tt.target, got, tt.want)
+			}
+		})
+	}
+}
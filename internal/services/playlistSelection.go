@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/CallumClarke65/spotify-analytics/internal/spotifyauth"
+	"github.com/zmb3/spotify/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	PlaylistOwnerMe     = "me"
+	PlaylistOwnerOthers = "others"
+)
+
+// PlaylistSelection godoc
+// @Description Which of the user's playlists to use. Every rule that is set must pass; an empty selection uses every playlist.
+// @Description Name patterns are Go regular expressions (prefix with (?i) to ignore case). Owner is "me", "others" or a Spotify user ID.
+// @name PlaylistSelection
+type PlaylistSelection struct {
+	IncludeIDs            []string `json:"includeIds"`
+	ExcludeIDs            []string `json:"excludeIds"`
+	IncludeNamePatterns   []string `json:"includeNamePatterns" example:"(?i)^20[0-9]{2}"`
+	ExcludeNamePatterns   []string `json:"excludeNamePatterns"`
+	ExcludeNameSubstrings []string `json:"excludeNameSubstrings"` // case-insensitive
+	Owner                 string   `json:"owner" example:"me"`
+	Collaborative         *bool    `json:"collaborative"`
+	MinTracks             *int     `json:"minTracks"`
+	MaxTracks             *int     `json:"maxTracks"`
+}
+
+// PlaylistSummary godoc
+// @Description A playlist that was used to build a response
+// @name PlaylistSummary
+type PlaylistSummary struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	OwnerID       string `json:"owner_id"`
+	Collaborative bool   `json:"collaborative"`
+	TrackCount    int    `json:"track_count"`
+}
+
+func (s PlaylistSelection) Validate() error {
+	for _, pattern := range append(append([]string{}, s.IncludeNamePatterns...), s.ExcludeNamePatterns...) {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid name pattern %q: %w", pattern, err)
+		}
+	}
+	if s.MinTracks != nil && s.MaxTracks != nil && *s.MinTracks > *s.MaxTracks {
+		return fmt.Errorf("minTracks can't be more than maxTracks")
+	}
+	return nil
+}
+
+// SelectPlaylists returns the playlists that pass every rule in the selection. userID is
+// the current user, used for the "me" and "others" owner rules.
+func SelectPlaylists(playlists []spotify.SimplePlaylist, selection PlaylistSelection, userID string) []spotify.SimplePlaylist {
+	includeIDs := toSet(selection.IncludeIDs)
+	excludeIDs := toSet(selection.ExcludeIDs)
+	include := mustCompileAll(selection.IncludeNamePatterns)
+	exclude := mustCompileAll(selection.ExcludeNamePatterns)
+
+	lowerIgnored := make([]string, len(selection.ExcludeNameSubstrings))
+	for i, s := range selection.ExcludeNameSubstrings {
+		lowerIgnored[i] = strings.ToLower(s)
+	}
+
+	var selected []spotify.SimplePlaylist
+	for _, p := range playlists {
+		reason := playlistRejection(p, selection, userID, includeIDs, excludeIDs, include, exclude, lowerIgnored)
+		if reason != "" {
+			zap.L().Debug("Skipping playlist", zap.String("name", p.Name), zap.String("reason", reason))
+			continue
+		}
+		selected = append(selected, p)
+	}
+
+	return selected
+}
+
+func playlistRejection(
+	p spotify.SimplePlaylist,
+	selection PlaylistSelection,
+	userID string,
+	includeIDs, excludeIDs map[string]bool,
+	include, exclude []*regexp.Regexp,
+	lowerIgnored []string,
+) string {
+	id := p.ID.String()
+	if len(includeIDs) > 0 && !includeIDs[id] {
+		return "not in includeIds"
+	}
+	if excludeIDs[id] {
+		return "in excludeIds"
+	}
+
+	if len(include) > 0 {
+		matched := false
+		for _, re := range include {
+			if re.MatchString(p.Name) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return "name doesn't match includeNamePatterns"
+		}
+	}
+	for _, re := range exclude {
+		if re.MatchString(p.Name) {
+			return "name matches excludeNamePatterns"
+		}
+	}
+	for _, sub := range lowerIgnored {
+		if strings.Contains(strings.ToLower(p.Name), sub) {
+			return "name contains excluded substring"
+		}
+	}
+
+	switch selection.Owner {
+	case "":
+	case PlaylistOwnerMe:
+		if p.Owner.ID != userID {
+			return "not owned by user"
+		}
+	case PlaylistOwnerOthers:
+		if p.Owner.ID == userID {
+			return "owned by user"
+		}
+	default:
+		if p.Owner.ID != selection.Owner {
+			return "owned by someone else"
+		}
+	}
+
+	if selection.Collaborative != nil && p.Collaborative != *selection.Collaborative {
+		return "collaborative flag doesn't match"
+	}
+
+	trackCount := int(p.Tracks.Total)
+	if selection.MinTracks != nil && trackCount < *selection.MinTracks {
+		return "too few tracks"
+	}
+	if selection.MaxTracks != nil && trackCount > *selection.MaxTracks {
+		return "too many tracks"
+	}
+
+	return ""
+}
+
+// GetSelectedUserPlaylists fetches the user's playlists and applies the selection
+func GetSelectedUserPlaylists(
+	ctx context.Context,
+	client *spotify.Client,
+	selection PlaylistSelection,
+) ([]spotify.SimplePlaylist, error) {
+
+	allPlaylists, err := GetAllUserPlaylists(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	var userID string
+	if selection.Owner == PlaylistOwnerMe || selection.Owner == PlaylistOwnerOthers {
+		if userID = spotifyauth.UserIDFromContext(ctx); userID == "" {
+			return nil, ErrNoUserID
+		}
+	}
+
+	selected := SelectPlaylists(allPlaylists, selection, userID)
+	zap.L().Info("Selected user playlists", zap.Int("selected", len(selected)), zap.Int("total", len(allPlaylists)))
+	return selected, nil
+}
+
+func SummarisePlaylists(playlists []spotify.SimplePlaylist) []PlaylistSummary {
+	summaries := make([]PlaylistSummary, 0, len(playlists))
+	for _, p := range playlists {
+		summaries = append(summaries, PlaylistSummary{
+			ID:            p.ID.String(),
+			Name:          p.Name,
+			OwnerID:       p.Owner.ID,
+			Collaborative: p.Collaborative,
+			TrackCount:    int(p.Tracks.Total),
+		})
+	}
+	return summaries
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// mustCompileAll compiles patterns that have already been checked by Validate
+func mustCompileAll(patterns []string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		compiled = append(compiled, regexp.MustCompile(p))
+	}
+	return compiled
+}
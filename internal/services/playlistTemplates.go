@@ -0,0 +1,115 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	BucketLiked       = "liked"
+	BucketOnPlaylists = "onPlaylists"
+	BucketSuggestions = "suggestions"
+)
+
+// PlaylistTemplate godoc
+// @Description How to build one generated playlist. Name and description can use {year}, {period}, {user}, {trackCount} and {date}.
+// @Description {period} is the year, or the month ("2019-07") or quarter ("2019 Q3") when the analysis is narrowed to one.
+// @name PlaylistTemplate
+type PlaylistTemplate struct {
+	NameTemplate        string   `json:"nameTemplate" example:"{year} - favourites"`
+	DescriptionTemplate string   `json:"descriptionTemplate" example:"Generated playlist of favourites for {year}"`
+	Public              bool     `json:"public"`
+	Collaborative       bool     `json:"collaborative"`
+	Buckets             []string `json:"buckets" example:"onPlaylists,liked"` // any of liked, onPlaylists, suggestions
+}
+
+// PlaylistTemplateVars are the values substituted into a PlaylistTemplate
+type PlaylistTemplateVars struct {
+	Year   int
+	Period string
+	User   string
+	Date   time.Time
+}
+
+// DefaultYearPlaylistTemplates are used when a request doesn't specify any playlists
+func DefaultYearPlaylistTemplates() []PlaylistTemplate {
+	return []PlaylistTemplate{
+		{
+			NameTemplate:        "{period} - favourites",
+			DescriptionTemplate: "Generated playlist of favourites for {period}",
+			Buckets:             []string{BucketOnPlaylists, BucketLiked},
+		},
+		{
+			NameTemplate:        "{period} - suggestions",
+			DescriptionTemplate: "Generated playlist of suggested tracks for {period}",
+			Buckets:             []string{BucketSuggestions},
+		},
+	}
+}
+
+func (t PlaylistTemplate) Validate() error {
+	if strings.TrimSpace(t.NameTemplate) == "" {
+		return fmt.Errorf("nameTemplate is required")
+	}
+	if len(t.Buckets) == 0 {
+		return fmt.Errorf("playlist %q has no buckets", t.NameTemplate)
+	}
+	for _, b := range t.Buckets {
+		switch b {
+		case BucketLiked, BucketOnPlaylists, BucketSuggestions:
+		default:
+			return fmt.Errorf("unknown bucket %q", b)
+		}
+	}
+	// Spotify only allows collaborative playlists to be private
+	if t.Public && t.Collaborative {
+		return fmt.Errorf("playlist %q can't be both public and collaborative", t.NameTemplate)
+	}
+	return nil
+}
+
+// RenderPlaylistTemplate fills the template in using the tracks from its buckets, in bucket
+// order, keeping one version of songs that turn up in more than one bucket and leaving out
+// tracks that can't be played in the user's country
+func RenderPlaylistTemplate(
+	t PlaylistTemplate,
+	vars PlaylistTemplateVars,
+	buckets map[string][]TrackInfo,
+	dedupe string,
+) GeneratedPlaylist {
+
+	var tracks []TrackInfo
+	for _, b := range t.Buckets {
+		for _, track := range buckets[b] {
+			if track.Playable != nil && !*track.Playable {
+				continue
+			}
+			tracks = append(tracks, track)
+		}
+	}
+	tracks, _ = DedupeTrackInfos(tracks, dedupe)
+
+	trackIDs := make([]string, 0, len(tracks))
+	for _, track := range tracks {
+		trackIDs = append(trackIDs, track.TrackID)
+	}
+	trackIDs = uniqueTrackIDs(trackIDs)
+
+	replacer := strings.NewReplacer(
+		"{year}", strconv.Itoa(vars.Year),
+		"{period}", vars.Period,
+		"{user}", vars.User,
+		"{trackCount}", strconv.Itoa(len(trackIDs)),
+		"{date}", vars.Date.Format(time.DateOnly),
+	)
+
+	return GeneratedPlaylist{
+		Name:          replacer.Replace(t.NameTemplate),
+		Description:   replacer.Replace(t.DescriptionTemplate),
+		Public:        t.Public,
+		Collaborative: t.Collaborative,
+		TrackIDs:      trackIDs,
+	}
+}
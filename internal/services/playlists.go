@@ -2,7 +2,6 @@ package services
 
 import (
 	"context"
-	"strings"
 
 	"github.com/zmb3/spotify/v2"
 	"go.uber.org/zap"
@@ -33,44 +32,44 @@ func GetAllUserPlaylists(ctx context.Context, client *spotify.Client) ([]spotify
 	return allPlaylists, nil
 }
 
+// GetFilteredUserPlaylists skips playlists whose names contain any of the substrings. See
+// GetSelectedUserPlaylists for the full set of selection rules.
 func GetFilteredUserPlaylists(
 	ctx context.Context,
 	client *spotify.Client,
 	ignoredPlaylistNameSubstrings []string,
 ) ([]spotify.SimplePlaylist, error) {
+	return GetSelectedUserPlaylists(ctx, client, PlaylistSelection{ExcludeNameSubstrings: ignoredPlaylistNameSubstrings})
+}
 
-	lowerIgnored := make([]string, len(ignoredPlaylistNameSubstrings))
-	for i, s := range ignoredPlaylistNameSubstrings {
-		lowerIgnored[i] = strings.ToLower(s)
-	}
-	zap.L().Debug("Ignoring playlists with substrings", zap.Strings("substrings", lowerIgnored))
+func GetAllPlaylistItems(
+	ctx context.Context,
+	client *spotify.Client,
+	playlist spotify.SimplePlaylist,
+) ([]spotify.PlaylistItem, error) {
 
-	allPlaylists, err := GetAllUserPlaylists(ctx, client)
+	var allItems []spotify.PlaylistItem
+
+	page, err := client.GetPlaylistItems(ctx, playlist.ID, marketOptions(ctx)...)
 	if err != nil {
 		return nil, err
 	}
+	allItems = append(allItems, page.Items...)
 
-	var filtered []spotify.SimplePlaylist
-
-	for _, p := range allPlaylists {
-
-		skip := false
-		for _, sub := range lowerIgnored {
-			if strings.Contains(strings.ToLower(p.Name), sub) {
-				skip = true
+	// Fetch remaining pages
+	for {
+		err := client.NextPage(ctx, page)
+		if err != nil {
+			if err == spotify.ErrNoMorePages {
 				break
 			}
+			zap.L().Warn("Failed to fetch next page of tracks", zap.Error(err))
+			break
 		}
-
-		if skip {
-			zap.L().Debug("Skipping playlist", zap.String("name", p.Name))
-			continue
-		}
-
-		filtered = append(filtered, p)
+		allItems = append(allItems, page.Items...)
 	}
 
-	return filtered, nil
+	return allItems, nil
 }
 
 func GetAllPlaylistTracks(
@@ -79,32 +78,18 @@ func GetAllPlaylistTracks(
 	playlist spotify.SimplePlaylist,
 ) ([]spotify.FullTrack, error) {
 
-	var allTracks []spotify.FullTrack
-
-	page, err := client.GetPlaylistItems(ctx, playlist.ID)
+	items, err := GetAllPlaylistItems(ctx, client, playlist)
 	if err != nil {
 		return nil, err
 	}
 
-	// Extract FullTrack from this page
-	for _, item := range page.Items {
-		allTracks = append(allTracks, *item.Track.Track)
-	}
-
-	// Fetch remaining pages
-	for {
-		err := client.NextPage(ctx, page)
-		if err != nil {
-			if err == spotify.ErrNoMorePages {
-				break
-			}
-			zap.L().Warn("Failed to fetch next page of tracks", zap.Error(err))
-			break
-		}
-
-		for _, item := range page.Items {
-			allTracks = append(allTracks, *item.Track.Track)
+	// Extract FullTrack from each item, skipping episodes and unavailable tracks
+	allTracks := make([]spotify.FullTrack, 0, len(items))
+	for _, item := range items {
+		if item.Track.Track == nil {
+			continue
 		}
+		allTracks = append(allTracks, *item.Track.Track)
 	}
 
 	zap.L().Info(
@@ -0,0 +1,313 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	RankByPopularity    = "popularity"
+	RankByPlaylistCount = "playlistCount"
+	RankByEarliestAdded = "earliestAdded"
+	RankByTopTracks     = "topTracks"
+	RankWeighted        = "weighted"
+)
+
+// RankingOptions godoc
+// @Description How to order tracks. Strategy is one of popularity (default), playlistCount, earliestAdded, topTracks or weighted.
+// @Description Weights apply to the weighted strategy and are keyed by the other strategy names; all are weighted equally if omitted.
+// @name RankingOptions
+type RankingOptions struct {
+	Strategy string             `json:"strategy" example:"weighted"`
+	Weights  map[string]float64 `json:"weights"`
+}
+
+// RankingSignals is the library data that ranking strategies other than popularity need
+type RankingSignals struct {
+	PlaylistCounts map[string]int       // track ID -> number of the user's playlists it's on
+	EarliestAdded  map[string]time.Time // track ID -> first time it was saved or added to a playlist
+	TopTrackRanges []string             // time ranges the top track ranks are for
+	TopTrackRanks  []map[string]int     // per time range, track ID -> rank starting at 1
+	TopTrackTotals []int                // per time range, number of ranked tracks
+}
+
+// rankingComponent scores every track between 0 and 1, relative to the other tracks being ranked
+type rankingComponent func(tracks []TrackInfo, signals *RankingSignals) []float64
+
+var rankingComponents = map[string]rankingComponent{
+	RankByPopularity:    popularityComponent,
+	RankByPlaylistCount: playlistCountComponent,
+	RankByEarliestAdded: earliestAddedComponent,
+	RankByTopTracks:     topTracksComponent,
+}
+
+func (o RankingOptions) Validate() error {
+	switch o.Strategy {
+	case "", RankWeighted:
+	default:
+		if _, ok := rankingComponents[o.Strategy]; !ok {
+			return fmt.Errorf("unknown ranking strategy %q", o.Strategy)
+		}
+	}
+	total := 0.0
+	for name, weight := range o.Weights {
+		if _, ok := rankingComponents[name]; !ok {
+			return fmt.Errorf("unknown ranking weight %q", name)
+		}
+		if weight < 0 {
+			return fmt.Errorf("ranking weight %q can't be negative", name)
+		}
+		total += weight
+	}
+	if len(o.Weights) > 0 && total == 0 {
+		return errors.New("ranking weights can't all be 0")
+	}
+	return nil
+}
+
+// weights returns the weight of each component the strategy uses
+func (o RankingOptions) weights() map[string]float64 {
+	switch o.Strategy {
+	case "":
+		return map[string]float64{RankByPopularity: 1}
+	case RankWeighted:
+		if len(o.Weights) > 0 {
+			return o.Weights
+		}
+		all := map[string]float64{}
+		for name := range rankingComponents {
+			all[name] = 1
+		}
+		return all
+	default:
+		return map[string]float64{o.Strategy: 1}
+	}
+}
+
+// BuildRankingSignals gets only the library data the chosen strategy needs, reusing the
+// liked songs and playlists already in the collection and fetching the rest. Playlist
+// counts and added dates only consider the playlists picked by the selection. Top tracks
+// are also fetched when provenance is wanted, as they're recorded in each track's sources;
+// a time range that fails to load is left out rather than failing the request.
+func BuildRankingSignals(
+	ctx context.Context,
+	client *spotify.Client,
+	opts RankingOptions,
+	selection PlaylistSelection,
+	collection *TrackCollection,
+	provenance bool,
+) (*RankingSignals, error) {
+	signals := &RankingSignals{}
+	weights := opts.weights()
+
+	_, needPlaylists := weights[RankByPlaylistCount]
+	_, needAdded := weights[RankByEarliestAdded]
+	_, needTopTracks := weights[RankByTopTracks]
+
+	if needPlaylists || needAdded {
+		signals.PlaylistCounts = map[string]int{}
+		signals.EarliestAdded = map[string]time.Time{}
+
+		playlistItems := collection.playlistItems
+		if playlistItems == nil {
+			playlists, err := GetSelectedUserPlaylists(ctx, client, selection)
+			if err != nil {
+				return nil, err
+			}
+			collection.UsePlaylists(playlists)
+			for _, p := range playlists {
+				items, err := GetAllPlaylistItems(ctx, client, p)
+				if err != nil {
+					zap.L().Warn("Failed to fetch playlist for ranking", zap.String("playlist_name", p.Name), zap.Error(err))
+					continue
+				}
+				playlistItems = append(playlistItems, items)
+			}
+		}
+
+		for _, items := range playlistItems {
+			onPlaylist := map[string]bool{}
+			for _, item := range items {
+				if item.Track.Track == nil {
+					continue
+				}
+				id := item.Track.Track.ID.String()
+				if !onPlaylist[id] {
+					onPlaylist[id] = true
+					signals.PlaylistCounts[id]++
+				}
+				signals.recordAdded(id, item.AddedAt)
+			}
+		}
+	}
+
+	if needAdded {
+		saved := collection.likedItems
+		if saved == nil {
+			var err error
+			if saved, err = GetAllUserSavedTrackItems(ctx, client); err != nil {
+				return nil, err
+			}
+		}
+		for _, t := range saved {
+			signals.recordAdded(t.ID.String(), t.AddedAt)
+		}
+	}
+
+	if needTopTracks || provenance {
+		for _, tr := range []spotify.Range{spotify.ShortTermRange, spotify.MediumTermRange, spotify.LongTermRange} {
+			top, err := GetTopTracks(ctx, client, tr)
+			if err != nil {
+				zap.L().Warn("Failed to fetch top tracks for ranking", zap.String("time_range", string(tr)), zap.Error(err))
+				continue
+			}
+			ranks := make(map[string]int, len(top))
+			for i, t := range top {
+				ranks[t.ID.String()] = i + 1
+			}
+			signals.TopTrackRanges = append(signals.TopTrackRanges, string(tr))
+			signals.TopTrackRanks = append(signals.TopTrackRanks, ranks)
+			signals.TopTrackTotals = append(signals.TopTrackTotals, len(top))
+		}
+	}
+
+	return signals, nil
+}
+
+// MarkTopTracks records the top tracks fetched for ranking in the collection's provenance
+func (s *RankingSignals) MarkTopTracks(c *TrackCollection) {
+	for i, ranks := range s.TopTrackRanks {
+		ids := make([]string, 0, len(ranks))
+		for id := range ranks {
+			ids = append(ids, id)
+		}
+		c.MarkTopTracks(s.TopTrackRanges[i], ids)
+	}
+}
+
+func (s *RankingSignals) recordAdded(trackID, addedAt string) {
+	added, err := time.Parse(spotify.TimestampLayout, addedAt)
+	if err != nil {
+		// Very old playlists don't record when tracks were added
+		return
+	}
+	if existing, ok := s.EarliestAdded[trackID]; !ok || added.Before(existing) {
+		s.EarliestAdded[trackID] = added
+	}
+}
+
+// RankTracks scores the tracks with the chosen strategy, records the score and its parts
+// on each track, and sorts them best first
+func RankTracks(tracks []TrackInfo, opts RankingOptions, signals *RankingSignals) {
+	weights := opts.weights()
+
+	totalWeight := 0.0
+	for _, w := range weights {
+		totalWeight += w
+	}
+
+	for i := range tracks {
+		tracks[i].Score = 0
+		tracks[i].ScoreComponents = make(map[string]float64, len(weights))
+	}
+
+	for name, weight := range weights {
+		scores := rankingComponents[name](tracks, signals)
+		for i, score := range scores {
+			tracks[i].ScoreComponents[name] = score
+			if totalWeight > 0 {
+				tracks[i].Score += score * weight / totalWeight
+			}
+		}
+	}
+
+	sort.SliceStable(tracks, func(i, j int) bool {
+		return tracks[i].Score > tracks[j].Score
+	})
+}
+
+func popularityComponent(tracks []TrackInfo, _ *RankingSignals) []float64 {
+	scores := make([]float64, len(tracks))
+	for i, t := range tracks {
+		scores[i] = float64(t.Popularity) / 100
+	}
+	return scores
+}
+
+func playlistCountComponent(tracks []TrackInfo, signals *RankingSignals) []float64 {
+	maxCount := 0
+	for _, t := range tracks {
+		if c := signals.PlaylistCounts[t.TrackID]; c > maxCount {
+			maxCount = c
+		}
+	}
+
+	scores := make([]float64, len(tracks))
+	if maxCount == 0 {
+		return scores
+	}
+	for i, t := range tracks {
+		scores[i] = float64(signals.PlaylistCounts[t.TrackID]) / float64(maxCount)
+	}
+	return scores
+}
+
+// earliestAddedComponent gives 1 to the track added first and 0 to the one added last.
+// Tracks with no known added date score 0.
+func earliestAddedComponent(tracks []TrackInfo, signals *RankingSignals) []float64 {
+	var earliest, latest time.Time
+	for _, t := range tracks {
+		added, ok := signals.EarliestAdded[t.TrackID]
+		if !ok {
+			continue
+		}
+		if earliest.IsZero() || added.Before(earliest) {
+			earliest = added
+		}
+		if latest.IsZero() || added.After(latest) {
+			latest = added
+		}
+	}
+
+	scores := make([]float64, len(tracks))
+	span := latest.Sub(earliest)
+	for i, t := range tracks {
+		added, ok := signals.EarliestAdded[t.TrackID]
+		if !ok {
+			continue
+		}
+		if span == 0 {
+			scores[i] = 1
+			continue
+		}
+		scores[i] = 1 - float64(added.Sub(earliest))/float64(span)
+	}
+	return scores
+}
+
+// topTracksComponent averages how high the track ranks across the three time ranges,
+// counting 0 for ranges it doesn't appear in
+func topTracksComponent(tracks []TrackInfo, signals *RankingSignals) []float64 {
+	scores := make([]float64, len(tracks))
+	if len(signals.TopTrackRanks) == 0 {
+		return scores
+	}
+	for i, t := range tracks {
+		for r, ranks := range signals.TopTrackRanks {
+			rank, ok := ranks[t.TrackID]
+			if !ok {
+				continue
+			}
+			total := signals.TopTrackTotals[r]
+			scores[i] += float64(total-rank+1) / float64(total)
+		}
+		scores[i] /= float64(len(signals.TopTrackRanks))
+	}
+	return scores
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+const (
+	ReleaseDatePrecisionYear  = "year"
+	ReleaseDatePrecisionMonth = "month"
+	ReleaseDatePrecisionDay   = "day"
+)
+
+// ReleaseDate is an album release date as precise as Spotify knows it. Month and Day are 0
+// when the precision doesn't include them.
+type ReleaseDate struct {
+	Year      int
+	Month     int
+	Day       int
+	Precision string
+}
+
+// releaseDateLayouts is the layout of each precision
+var releaseDateLayouts = map[string]string{
+	ReleaseDatePrecisionYear:  "2006",
+	ReleaseDatePrecisionMonth: "2006-01",
+	ReleaseDatePrecisionDay:   time.DateOnly,
+}
+
+// ParseReleaseDate reads a Spotify release date ("1981", "1981-12" or "1981-12-15"). The
+// precision is inferred from the string when Spotify doesn't provide it, doesn't recognise
+// it, or claims more than the string holds ("day" for "1981"). Dates that can't be read
+// (empty, "0", "0000" or malformed) return false.
+func ParseReleaseDate(date, precision string) (ReleaseDate, bool) {
+	date = strings.TrimSpace(date)
+	layout, ok := releaseDateLayouts[precision]
+	if !ok || len(date) < len(layout) {
+		switch {
+		case len(date) < len("2006-01"):
+			precision = ReleaseDatePrecisionYear
+		case len(date) < len(time.DateOnly):
+			precision = ReleaseDatePrecisionMonth
+		default:
+			precision = ReleaseDatePrecisionDay
+		}
+		layout = releaseDateLayouts[precision]
+	}
+
+	// Spotify sometimes reports a more precise date than its precision claims
+	if len(date) > len(layout) {
+		date = date[:len(layout)]
+	}
+
+	parsed, err := time.Parse(layout, date)
+	if err != nil || parsed.Year() == 0 {
+		return ReleaseDate{}, false
+	}
+
+	result := ReleaseDate{Year: parsed.Year(), Precision: precision}
+	if precision != ReleaseDatePrecisionYear {
+		result.Month = int(parsed.Month())
+	}
+	if precision == ReleaseDatePrecisionDay {
+		result.Day = parsed.Day()
+	}
+	return result, true
+}
+
+// TrackReleaseDate returns the release date of the track's album
+func TrackReleaseDate(track spotify.FullTrack) (ReleaseDate, bool) {
+	return ParseReleaseDate(track.Album.ReleaseDate, track.Album.ReleaseDatePrecision)
+}
+
+func (d ReleaseDate) HasMonth() bool {
+	return d.Month != 0
+}
+
+// Before compares dates field by field; a less precise date sorts before a more precise
+// one in the same year or month
+func (d ReleaseDate) Before(other ReleaseDate) bool {
+	if d.Year != other.Year {
+		return d.Year < other.Year
+	}
+	if d.Month != other.Month {
+		return d.Month < other.Month
+	}
+	return d.Day < other.Day
+}
+
+func (d ReleaseDate) String() string {
+	switch {
+	case d.Day != 0:
+		return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+	case d.Month != 0:
+		return fmt.Sprintf("%04d-%02d", d.Year, d.Month)
+	default:
+		return fmt.Sprintf("%04d", d.Year)
+	}
+}
+
+// ReleasePeriod is a whole year, or a range of months within one year
+type ReleasePeriod struct {
+	Year      int
+	FromMonth int // 0 for the whole year
+	ToMonth   int
+}
+
+func YearPeriod(year int) ReleasePeriod {
+	return ReleasePeriod{Year: year}
+}
+
+func MonthPeriod(year, month int) (ReleasePeriod, error) {
+	if month < 1 || month > 12 {
+		return ReleasePeriod{}, fmt.Errorf("month must be between 1 and 12")
+	}
+	return ReleasePeriod{Year: year, FromMonth: month, ToMonth: month}, nil
+}
+
+func QuarterPeriod(year, quarter int) (ReleasePeriod, error) {
+	if quarter < 1 || quarter > 4 {
+		return ReleasePeriod{}, fmt.Errorf("quarter must be between 1 and 4")
+	}
+	from := (quarter-1)*3 + 1
+	return ReleasePeriod{Year: year, FromMonth: from, ToMonth: from + 2}, nil
+}
+
+// ParseQuarter accepts "3" or "Q3"
+func ParseQuarter(raw string) (int, error) {
+	quarter, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(raw), "Q"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid quarter %q", raw)
+	}
+	return quarter, nil
+}
+
+// Contains reports whether a release date falls in the period. Dates only known to the
+// year never match a period narrower than a year, since their month is unknown.
+func (p ReleasePeriod) Contains(d ReleaseDate) bool {
+	if d.Year != p.Year {
+		return false
+	}
+	if p.FromMonth == 0 {
+		return true
+	}
+	return d.HasMonth() && d.Month >= p.FromMonth && d.Month <= p.ToMonth
+}
+
+// String is used in generated playlist names, e.g. "2019", "2019-07" or "2019 Q3"
+func (p ReleasePeriod) String() string {
+	switch {
+	case p.FromMonth == 0:
+		return strconv.Itoa(p.Year)
+	case p.FromMonth == p.ToMonth:
+		return fmt.Sprintf("%04d-%02d", p.Year, p.FromMonth)
+	case p.ToMonth-p.FromMonth == 2 && p.FromMonth%3 == 1:
+		return fmt.Sprintf("%04d Q%d", p.Year, (p.FromMonth-1)/3+1)
+	default:
+		return fmt.Sprintf("%04d-%02d to %04d-%02d", p.Year, p.FromMonth, p.Year, p.ToMonth)
+	}
+}
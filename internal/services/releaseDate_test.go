@@ -0,0 +1,43 @@
+package services
+
+import "testing"
+
+func TestParseReleaseDate(t *testing.T) {
+	tests := []struct {
+		name      string
+		date      string
+		precision string
+		want      ReleaseDate
+		ok        bool
+	}{
+		{"year", "1981", "year", ReleaseDate{Year: 1981, Precision: "year"}, true},
+		{"month", "1981-12", "month", ReleaseDate{Year: 1981, Month: 12, Precision: "month"}, true},
+		{"day", "1981-12-15", "day", ReleaseDate{Year: 1981, Month: 12, Day: 15, Precision: "day"}, true},
+		{"precision inferred", "1981-12", "", ReleaseDate{Year: 1981, Month: 12, Precision: "month"}, true},
+		{"unknown precision", "1981-12-15", "week", ReleaseDate{Year: 1981, Month: 12, Day: 15, Precision: "day"}, true},
+		{"surrounding space", " 1981 ", "year", ReleaseDate{Year: 1981, Precision: "year"}, true},
+
+		{"day precision for a year", "1981", "day", ReleaseDate{Year: 1981, Precision: "year"}, true},
+		{"day precision for a month", "1981-12", "day", ReleaseDate{Year: 1981, Month: 12, Precision: "month"}, true},
+		{"month precision for a year", "1981", "month", ReleaseDate{Year: 1981, Precision: "year"}, true},
+		{"year precision for a day", "1981-12-15", "year", ReleaseDate{Year: 1981, Precision: "year"}, true},
+		{"month precision for a day", "1981-12-15", "month", ReleaseDate{Year: 1981, Month: 12, Precision: "month"}, true},
+
+		{"empty", "", "day", ReleaseDate{}, false},
+		{"zero", "0", "year", ReleaseDate{}, false},
+		{"zero year", "0000", "year", ReleaseDate{}, false},
+		{"letters", "abcd", "year", ReleaseDate{}, false},
+		{"bad month", "1981-13", "month", ReleaseDate{}, false},
+		{"bad day", "1981-02-30", "day", ReleaseDate{}, false},
+		{"slashes", "15/12/1981", "day", ReleaseDate{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseReleaseDate(tt.date, tt.precision)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("ParseReleaseDate(%q, %q) = %+v, %v; want %+v, %v", tt.date, tt.precision, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
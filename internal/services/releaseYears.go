@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/CallumClarke65/spotify-analytics/internal/spotifyauth"
+	"github.com/zmb3/spotify/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	// Entries are keyed on market, so this isn't the file older versions keyed on track ID alone
+	releaseYearCacheFile = "original_release_dates_by_market"
+	// Each lookup is up to two searches, so one request makes at most twice this many
+	maxOriginalReleaseLookups = 100
+	// New lookups are written to the cache file this often, so a request that's cut short
+	// doesn't lose them
+	originalReleaseCacheSaveEvery = 25
+)
+
+// Album names that mark a studio album as a later reissue, whose date isn't the original's
+var reissueMarker = regexp.MustCompile(`(?i)\b(remaster|remastered|deluxe|anniversary|expanded|reissue|edition)\b`)
+
+// originalRelease is the earliest known release of a recording
+type originalRelease struct {
+	ReleaseDate          string `json:"release_date"`
+	ReleaseDatePrecision string `json:"release_date_precision"`
+	AlbumName            string `json:"album_name"`
+}
+
+var (
+	originalReleaseCache     map[string]originalRelease // market and track ID -> original release
+	originalReleaseCacheLock sync.Mutex
+	originalReleaseCacheOnce sync.Once
+)
+
+type originalReleaseBudgetKey struct{}
+
+// originalReleaseBudget is how many lookups a request has left, shared by every
+// ResolveOriginalReleaseDates call made with the request's context
+type originalReleaseBudget struct {
+	mu   sync.Mutex
+	left int
+}
+
+// WithOriginalReleaseBudget returns a context carrying one budget of
+// maxOriginalReleaseLookups, for handlers that resolve more than one list of tracks
+func WithOriginalReleaseBudget(ctx context.Context) context.Context {
+	return context.WithValue(ctx, originalReleaseBudgetKey{}, &originalReleaseBudget{left: maxOriginalReleaseLookups})
+}
+
+func originalReleaseBudgetFromContext(ctx context.Context) *originalReleaseBudget {
+	if budget, ok := ctx.Value(originalReleaseBudgetKey{}).(*originalReleaseBudget); ok {
+		return budget
+	}
+	return &originalReleaseBudget{left: maxOriginalReleaseLookups}
+}
+
+// take uses up one lookup, reporting false if there were none left
+func (b *originalReleaseBudget) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.left <= 0 {
+		return false
+	}
+	b.left--
+	return true
+}
+
+func loadOriginalReleaseCache() {
+	originalReleaseCacheOnce.Do(func() {
+		originalReleaseCache = map[string]originalRelease{}
+		if err := ReadJsonObjectFromFile(&originalReleaseCache, releaseYearCacheFile); err != nil {
+			zap.L().Debug("No original release date cache loaded", zap.Error(err))
+		}
+	})
+}
+
+// ResolveOriginalReleaseDates returns copies of the tracks with Album.ReleaseDate set to the
+// earliest studio-album release of the same recording, so remasters and compilations count
+// towards the year the song first came out. Only tracks whose album was released in or after
+// releasedFrom are looked up, since resolving can only move a track earlier; pass 0 to
+// resolve every track. Tracks already on a studio album that isn't a reissue are taken as
+// original. Lookups are cached across requests, and at most maxOriginalReleaseLookups are
+// made per context given WithOriginalReleaseBudget, or per call without one; tracks past
+// that keep their own date until a later request reaches them.
+func ResolveOriginalReleaseDates(
+	ctx context.Context,
+	client *spotify.Client,
+	tracks []spotify.FullTrack,
+	releasedFrom int,
+) []spotify.FullTrack {
+	loadOriginalReleaseCache()
+	budget := originalReleaseBudgetFromContext(ctx)
+
+	resolved := make([]spotify.FullTrack, len(tracks))
+	copy(resolved, tracks)
+
+	lookedUp, skipped := 0, 0
+	for i, t := range resolved {
+		releaseDate, ok := TrackReleaseDate(t)
+		if !ok || releaseDate.Year < releasedFrom || t.ID == "" || isOriginalAlbumRelease(t) {
+			continue
+		}
+
+		key := originalReleaseCacheKey(ctx, t.ID)
+		originalReleaseCacheLock.Lock()
+		original, cached := originalReleaseCache[key]
+		originalReleaseCacheLock.Unlock()
+
+		if !cached {
+			if ctx.Err() != nil || !budget.take() {
+				skipped++
+				continue
+			}
+			var err error
+			original, err = findOriginalRelease(ctx, client, t)
+			if err != nil {
+				zap.L().Warn("Failed to resolve original release", zap.String("track", t.Name), zap.Error(err))
+				continue
+			}
+			originalReleaseCacheLock.Lock()
+			originalReleaseCache[key] = original
+			originalReleaseCacheLock.Unlock()
+			lookedUp++
+			if lookedUp%originalReleaseCacheSaveEvery == 0 {
+				saveOriginalReleaseCache()
+			}
+		}
+
+		if original.ReleaseDate != t.Album.ReleaseDate {
+			resolved[i].Album.ReleaseDate = original.ReleaseDate
+			resolved[i].Album.ReleaseDatePrecision = original.ReleaseDatePrecision
+		}
+	}
+
+	if lookedUp%originalReleaseCacheSaveEvery != 0 {
+		saveOriginalReleaseCache()
+	}
+
+	zap.L().Info("Resolved original release dates",
+		zap.Int("tracks", len(tracks)),
+		zap.Int("looked_up", lookedUp),
+		zap.Int("over_budget", skipped),
+	)
+	return resolved
+}
+
+// originalReleaseCacheKey keys a lookup on the user's market as well as the track, since
+// searches only find the versions playable there
+func originalReleaseCacheKey(ctx context.Context, id spotify.ID) string {
+	return spotifyauth.MarketFromContext(ctx) + ":" + id.String()
+}
+
+func saveOriginalReleaseCache() {
+	originalReleaseCacheLock.Lock()
+	defer originalReleaseCacheLock.Unlock()
+	if err := WriteJsonObjectToFile(originalReleaseCache, releaseYearCacheFile); err != nil {
+		zap.L().Warn("Failed to save original release date cache", zap.Error(err))
+	}
+}
+
+// isOriginalAlbumRelease reports whether the track is on a studio album with a full release
+// date and no reissue marker, which searching wouldn't find an earlier release for
+func isOriginalAlbumRelease(t spotify.FullTrack) bool {
+	return t.Album.AlbumType == "album" &&
+		t.Album.ReleaseDatePrecision == "day" &&
+		!reissueMarker.MatchString(t.Album.Name) &&
+		!reissueMarker.MatchString(t.Name)
+}
+
+// findOriginalRelease searches for other versions of the track by ISRC and by normalised
+// title plus primary artist. The earliest studio album wins; failing that the earliest
+// non-compilation release; and the track's own release if nothing earlier turns up.
+func findOriginalRelease(ctx context.Context, client *spotify.Client, track spotify.FullTrack) (originalRelease, error) {
+	var candidates []spotify.FullTrack
+
+	if isrc := track.ExternalIDs["isrc"]; isrc != "" {
+		sr, err := client.Search(ctx, "isrc:"+isrc, spotify.SearchTypeTrack, append(marketOptions(ctx), spotify.Limit(50))...)
+		if err != nil {
+			return originalRelease{}, err
+		}
+		candidates = append(candidates, sr.Tracks.Tracks...)
+	}
+
+	title := NormaliseTrackTitle(track.Name)
+	if len(track.Artists) > 0 && title != "" {
+		primary := track.Artists[0]
+		query := fmt.Sprintf("track:%s artist:%s", quoteSearchTerm(title), quoteSearchTerm(primary.Name))
+		sr, err := client.Search(ctx, query, spotify.SearchTypeTrack, append(marketOptions(ctx), spotify.Limit(50))...)
+		if err != nil {
+			return originalRelease{}, err
+		}
+		for _, c := range sr.Tracks.Tracks {
+			if NormaliseTrackTitle(c.Name) != title || len(c.Artists) == 0 {
+				continue
+			}
+			if c.Artists[0].ID != primary.ID && NormaliseName(c.Artists[0].Name) != NormaliseName(primary.Name) {
+				continue
+			}
+			candidates = append(candidates, c)
+		}
+	}
+
+	best := originalRelease{
+		ReleaseDate:          track.Album.ReleaseDate,
+		ReleaseDatePrecision: track.Album.ReleaseDatePrecision,
+		AlbumName:            track.Album.Name,
+	}
+
+	var studio, other *spotify.FullTrack
+	var studioDate, otherDate ReleaseDate
+	for i := range candidates {
+		c := &candidates[i]
+		date, ok := TrackReleaseDate(*c)
+		if !ok || IsLiveVersion(c.Name) {
+			continue
+		}
+		switch c.Album.AlbumType {
+		case "album":
+			if studio == nil || date.Before(studioDate) {
+				studio, studioDate = c, date
+			}
+		case "compilation":
+		default:
+			if other == nil || date.Before(otherDate) {
+				other, otherDate = c, date
+			}
+		}
+	}
+
+	pick, pickDate := studio, studioDate
+	if pick == nil {
+		pick, pickDate = other, otherDate
+	}
+	ownDate, ownDateOK := TrackReleaseDate(track)
+	if pick != nil && (!ownDateOK || pickDate.Before(ownDate)) {
+		best = originalRelease{
+			ReleaseDate:          pick.Album.ReleaseDate,
+			ReleaseDatePrecision: pick.Album.ReleaseDatePrecision,
+			AlbumName:            pick.Album.Name,
+		}
+	}
+
+	return best, nil
+}
+
+// quoteSearchTerm wraps a value in double quotes for a Spotify search field filter. The
+// search syntax has no way to escape quotes, and a colon can still start a field filter, so
+// both are replaced with spaces.
+func quoteSearchTerm(value string) string {
+	value = strings.NewReplacer(`"`, " ", ":", " ").Replace(value)
+	return `"` + strings.Join(strings.Fields(value), " ") + `"`
+}
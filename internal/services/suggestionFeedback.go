@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+const (
+	FeedbackLike        = "like"
+	FeedbackDislike     = "dislike"
+	FeedbackNeverArtist = "neverArtist"
+
+	// Liked tracks by an artist needed for each extra track per run, and the most extra
+	likesPerExtraSlot = 2
+	maxExtraSlots     = 5
+
+	// Spotify looks up at most 50 tracks per call
+	trackLookupBatchSize = 50
+)
+
+// Spotify IDs are 22 base62 characters
+var spotifyIDPattern = regexp.MustCompile(`^[0-9A-Za-z]{22}$`)
+
+// ErrUnknownTrack is returned for feedback on a track ID Spotify doesn't know
+var ErrUnknownTrack = errors.New("unknown track")
+
+// SuggestionFeedbackRequest godoc
+// @Description Feedback on suggested tracks. like and dislike apply to the tracks; neverArtist blocks each track's main artist from future suggestions.
+// @name SuggestionFeedbackRequest
+type SuggestionFeedbackRequest struct {
+	Action   string   `json:"action" example:"like"` // like, dislike or neverArtist
+	TrackIDs []string `json:"trackIds" example:"4uLU6hMCjMI75M1A2tKUQC"`
+}
+
+// FeedbackTrack godoc
+// @Description A track the user gave feedback on
+// @name FeedbackTrack
+type FeedbackTrack struct {
+	Name      string   `json:"name"`
+	ArtistIDs []string `json:"artist_ids"`
+	At        string   `json:"at"`
+}
+
+// FeedbackArtist godoc
+// @Description An artist the user never wants suggested
+// @name FeedbackArtist
+type FeedbackArtist struct {
+	Name string `json:"name"`
+	At   string `json:"at"`
+}
+
+// SuggestionFeedback godoc
+// @Description Everything a user has said about their suggestions, by track or artist ID
+// @name SuggestionFeedback
+type SuggestionFeedback struct {
+	Liked          map[string]FeedbackTrack  `json:"liked"`
+	Disliked       map[string]FeedbackTrack  `json:"disliked"`
+	BlockedArtists map[string]FeedbackArtist `json:"blocked_artists"`
+}
+
+func (r SuggestionFeedbackRequest) Validate() error {
+	switch r.Action {
+	case FeedbackLike, FeedbackDislike, FeedbackNeverArtist:
+	default:
+		return fmt.Errorf("unknown action %q", r.Action)
+	}
+	if len(r.TrackIDs) == 0 {
+		return fmt.Errorf("trackIds is required")
+	}
+	for _, id := range r.TrackIDs {
+		if !spotifyIDPattern.MatchString(id) {
+			return fmt.Errorf("invalid track ID %q", id)
+		}
+	}
+	return nil
+}
+
+func newSuggestionFeedback() SuggestionFeedback {
+	return SuggestionFeedback{
+		Liked:          map[string]FeedbackTrack{},
+		Disliked:       map[string]FeedbackTrack{},
+		BlockedArtists: map[string]FeedbackArtist{},
+	}
+}
+
+// Guards reading and writing every user's feedback file
+var suggestionFeedbackLock sync.Mutex
+
+func suggestionFeedbackFile(userID string) string {
+	return "suggestion_feedback_" + userID
+}
+
+func readSuggestionFeedback(userID string) (SuggestionFeedback, error) {
+	if userID == "" {
+		return SuggestionFeedback{}, ErrNoUserID
+	}
+	feedback := newSuggestionFeedback()
+	err := ReadJsonObjectFromFile(&feedback, suggestionFeedbackFile(userID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return newSuggestionFeedback(), nil
+	}
+	if err != nil {
+		return SuggestionFeedback{}, err
+	}
+	// Files written before a map had entries decode it as nil
+	if feedback.Liked == nil {
+		feedback.Liked = map[string]FeedbackTrack{}
+	}
+	if feedback.Disliked == nil {
+		feedback.Disliked = map[string]FeedbackTrack{}
+	}
+	if feedback.BlockedArtists == nil {
+		feedback.BlockedArtists = map[string]FeedbackArtist{}
+	}
+	return feedback, nil
+}
+
+// LoadSuggestionFeedback returns the user's stored feedback, empty if they haven't given any
+func LoadSuggestionFeedback(userID string) (SuggestionFeedback, error) {
+	suggestionFeedbackLock.Lock()
+	defer suggestionFeedbackLock.Unlock()
+	return readSuggestionFeedback(userID)
+}
+
+// RecordSuggestionFeedback looks the tracks up and stores the feedback against them.
+// Liking a track clears a dislike of it and the other way round.
+func RecordSuggestionFeedback(ctx context.Context, client *spotify.Client, userID string, req SuggestionFeedbackRequest) (SuggestionFeedback, error) {
+	if userID == "" {
+		return SuggestionFeedback{}, ErrNoUserID
+	}
+	tracks, err := lookUpTracks(ctx, client, req.TrackIDs)
+	if err != nil {
+		return SuggestionFeedback{}, err
+	}
+
+	suggestionFeedbackLock.Lock()
+	defer suggestionFeedbackLock.Unlock()
+
+	feedback, err := readSuggestionFeedback(userID)
+	if err != nil {
+		return SuggestionFeedback{}, err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, t := range tracks {
+		id := t.ID.String()
+		entry := FeedbackTrack{Name: t.Name, ArtistIDs: []string{}, At: now}
+		for _, a := range t.Artists {
+			entry.ArtistIDs = append(entry.ArtistIDs, a.ID.String())
+		}
+
+		switch req.Action {
+		case FeedbackLike:
+			feedback.Liked[id] = entry
+			delete(feedback.Disliked, id)
+		case FeedbackDislike:
+			feedback.Disliked[id] = entry
+			delete(feedback.Liked, id)
+		case FeedbackNeverArtist:
+			if len(t.Artists) > 0 {
+				feedback.BlockedArtists[t.Artists[0].ID.String()] = FeedbackArtist{Name: t.Artists[0].Name, At: now}
+			}
+		}
+	}
+
+	if err := WriteJsonObjectToFile(feedback, suggestionFeedbackFile(userID)); err != nil {
+		return SuggestionFeedback{}, err
+	}
+	return feedback, nil
+}
+
+// lookUpTracks fetches the tracks a batch at a time, erroring on IDs Spotify doesn't know
+func lookUpTracks(ctx context.Context, client *spotify.Client, trackIDs []string) ([]spotify.FullTrack, error) {
+	var tracks []spotify.FullTrack
+	for start := 0; start < len(trackIDs); start += trackLookupBatchSize {
+		batch := trackIDs[start:min(start+trackLookupBatchSize, len(trackIDs))]
+		ids := make([]spotify.ID, len(batch))
+		for i, id := range batch {
+			ids[i] = spotify.ID(id)
+		}
+
+		found, err := client.GetTracks(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		for i, t := range found {
+			if t == nil {
+				return nil, fmt.Errorf("%w %q", ErrUnknownTrack, batch[i])
+			}
+			tracks = append(tracks, *t)
+		}
+	}
+	return tracks, nil
+}
+
+// artistSlots is how many tracks to pick for a seed artist, more than base for artists the
+// user has liked suggestions from
+func (f SuggestionFeedback) artistSlots(artistID string, base int) int {
+	likes := 0
+	for _, t := range f.Liked {
+		for _, id := range t.ArtistIDs {
+			if id == artistID {
+				likes++
+				break
+			}
+		}
+	}
+	return base + min(likes/likesPerExtraSlot, maxExtraSlots)
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+const (
+	PreviouslySuggestedDownrank = "downrank"
+	PreviouslySuggestedSkip     = "skip"
+	PreviouslySuggestedInclude  = "include"
+
+	// Score multiplier for tracks that have been suggested before
+	previouslySuggestedPenalty = 0.5
+
+	// Spotify checks at most 50 tracks per "user has saved tracks" call
+	savedTrackCheckBatchSize = 50
+)
+
+// suggestionHistoryEntry records when a track was suggested to the user
+type suggestionHistoryEntry struct {
+	FirstSuggested string `json:"first_suggested"`
+	LastSuggested  string `json:"last_suggested"`
+	Times          int    `json:"times"`
+}
+
+// suggestionHistory is every track ever suggested to one user, by track ID
+type suggestionHistory map[string]suggestionHistoryEntry
+
+// Guards reading and writing every user's history file
+var suggestionHistoryLock sync.Mutex
+
+func suggestionHistoryFile(userID string) string {
+	return "suggestion_history_" + userID
+}
+
+func loadSuggestionHistory(userID string) (suggestionHistory, error) {
+	if userID == "" {
+		return nil, ErrNoUserID
+	}
+
+	suggestionHistoryLock.Lock()
+	defer suggestionHistoryLock.Unlock()
+
+	history := suggestionHistory{}
+	err := ReadJsonObjectFromFile(&history, suggestionHistoryFile(userID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return suggestionHistory{}, nil
+	}
+	return history, err
+}
+
+// RecordSuggestedTracks adds the tracks shown to the user to their suggestion history
+func RecordSuggestedTracks(userID string, tracks []TrackInfo) error {
+	if len(tracks) == 0 {
+		return nil
+	}
+	if userID == "" {
+		return ErrNoUserID
+	}
+
+	suggestionHistoryLock.Lock()
+	defer suggestionHistoryLock.Unlock()
+
+	history := suggestionHistory{}
+	err := ReadJsonObjectFromFile(&history, suggestionHistoryFile(userID))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, t := range tracks {
+		entry, ok := history[t.TrackID]
+		if !ok {
+			entry.FirstSuggested = now
+		}
+		entry.LastSuggested = now
+		entry.Times++
+		history[t.TrackID] = entry
+	}
+
+	return WriteJsonObjectToFile(history, suggestionHistoryFile(userID))
+}
+
+// savedTrackIDs returns which of the tracks are in the user's liked songs. Relinked tracks
+// are checked under the ID that was asked for too, since that's the one the user saved.
+func savedTrackIDs(ctx context.Context, client *spotify.Client, tracks []spotify.FullTrack) (map[spotify.ID]bool, error) {
+	var all []spotify.ID
+	for _, t := range tracks {
+		all = append(all, t.ID)
+		if linked := linkedFromID(t); linked != "" {
+			all = append(all, linked)
+		}
+	}
+
+	saved := map[spotify.ID]bool{}
+	for start := 0; start < len(all); start += savedTrackCheckBatchSize {
+		ids := all[start:min(start+savedTrackCheckBatchSize, len(all))]
+
+		has, err := client.UserHasTracks(ctx, ids...)
+		if err != nil {
+			return nil, err
+		}
+		for i, h := range has {
+			if h {
+				saved[ids[i]] = true
+			}
+		}
+	}
+	return saved, nil
+}
+
+// removeKnownTracks drops search results the user has already saved or disliked, and ones
+// suggested before when previously suggested tracks are skipped, counting each in the report
+func removeKnownTracks(
+	ctx context.Context,
+	client *spotify.Client,
+	tracks []spotify.FullTrack,
+	history suggestionHistory,
+	feedback SuggestionFeedback,
+	previouslySuggested string,
+	report *SuggestionReport,
+) ([]spotify.FullTrack, error) {
+	saved, err := savedTrackIDs(ctx, client, tracks)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := make([]spotify.FullTrack, 0, len(tracks))
+	for _, t := range tracks {
+		if saved[t.ID] || saved[linkedFromID(t)] {
+			report.SkippedSaved++
+			continue
+		}
+		if _, disliked := feedback.Disliked[t.ID.String()]; disliked {
+			report.SkippedDisliked++
+			continue
+		}
+		if _, seen := history[t.ID.String()]; seen && previouslySuggested == PreviouslySuggestedSkip {
+			report.SkippedPreviouslySuggested++
+			continue
+		}
+		kept = append(kept, t)
+	}
+	return kept, nil
+}
+
+// downrank lowers the score of a track that was suggested on an earlier run
+func (s *SuggestedTrack) downrank(entry suggestionHistoryEntry) {
+	s.Score *= previouslySuggestedPenalty
+	s.ScoreComponents["previouslySuggested"] = previouslySuggestedPenalty
+
+	when := entry.LastSuggested
+	if t, err := time.Parse(time.RFC3339, entry.LastSuggested); err == nil {
+		when = t.Format(time.DateOnly)
+	}
+	s.Reasons = append(s.Reasons, "ranked lower: already suggested on "+when)
+}
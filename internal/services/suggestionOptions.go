@@ -0,0 +1,282 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+const (
+	GenreMatchSubstring = "substring"
+	GenreMatchExact     = "exact"
+
+	defaultTracksPerArtist = 5
+	defaultTracksPerAlbum  = 3
+	defaultSearchLimit     = 50
+	deepCutsSearchLimit    = 200
+	// Spotify won't page a search past 1000 results
+	maxSearchLimit = 1000
+)
+
+// GenreFilter godoc
+// @Description Which seed artists to keep by genre. Artists with a genre matching an exclude entry are dropped; when include is set, only artists with a genre matching an include entry are kept. Matching ignores case.
+// @name GenreFilter
+type GenreFilter struct {
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude" example:"classical"`
+	Match   string   `json:"match" example:"substring"` // substring (default) or exact
+}
+
+// SuggestionOptions godoc
+// @Description Options for building suggestions. Anything left out falls back to the user's stored defaults.
+// @name SuggestionOptions
+type SuggestionOptions struct {
+	Genres *GenreFilter `json:"genres,omitempty"`
+	// Where seed artists come from, defaulting to the user's top artists
+	Seeds          []SeedStrategy `json:"seeds,omitempty"`
+	MaxSeedArtists int            `json:"maxSeedArtists,omitempty" example:"75"`
+	// What to do with tracks suggested on an earlier run: downrank (default), skip or include
+	PreviouslySuggested string `json:"previouslySuggested,omitempty" example:"downrank"`
+	// Tracks picked per seed artist (5 by default) and per album (3 by default)
+	TracksPerArtist int `json:"tracksPerArtist,omitempty" example:"5"`
+	TracksPerAlbum  int `json:"tracksPerAlbum,omitempty" example:"3"`
+	// Search results looked at per seed artist, 50 by default or 200 for deep cuts, at most 1000
+	SearchLimit int `json:"searchLimit,omitempty" example:"50"`
+	// Most suggestions to return in total, no limit if left out
+	MaxSuggestions int `json:"maxSuggestions,omitempty" example:"100"`
+	// Prefer less popular tracks from albums the user already likes over the hits
+	DeepCuts *bool `json:"deepCuts,omitempty"`
+}
+
+// DroppedArtist godoc
+// @Description A seed artist that was left out of suggestions, and the rule that dropped it
+// @name DroppedArtist
+type DroppedArtist struct {
+	ArtistID string `json:"artist_id"`
+	Name     string `json:"name"`
+	Rule     string `json:"rule" example:"exclude:classical"` // exclude:<genre>, include or blocked
+	Genre    string `json:"genre,omitempty"`                  // the artist's genre that matched, for exclude rules
+}
+
+// SuggestionReport godoc
+// @Description How suggestions were put together
+// @name SuggestionReport
+type SuggestionReport struct {
+	Seeds                         []SeedArtist    `json:"seeds"`
+	DroppedArtists                []DroppedArtist `json:"dropped_artists"`
+	SkippedSaved                  int             `json:"skipped_saved"` // already in the user's liked songs
+	SkippedPreviouslySuggested    int             `json:"skipped_previously_suggested"`
+	SkippedDisliked               int             `json:"skipped_disliked"`
+	DownrankedPreviouslySuggested int             `json:"downranked_previously_suggested"`
+	MaxSuggestions                int             `json:"max_suggestions,omitempty"`
+	OverMaxSuggestions            int             `json:"over_max_suggestions"`    // left out by maxSuggestions
+	RejectedSearchResults         int             `json:"rejected_search_results"` // search hits by other artists
+	SkippedOtherVersions          int             `json:"skipped_other_versions"`  // other versions of a song already in the library or results
+	SkippedUnplayable             int             `json:"skipped_unplayable"`      // not playable in the user's country
+}
+
+func NewSuggestionReport() *SuggestionReport {
+	return &SuggestionReport{Seeds: []SeedArtist{}, DroppedArtists: []DroppedArtist{}}
+}
+
+func (f GenreFilter) Validate() error {
+	switch f.Match {
+	case "", GenreMatchSubstring, GenreMatchExact:
+		return nil
+	default:
+		return fmt.Errorf("unknown genre match %q", f.Match)
+	}
+}
+
+// Rejects returns the rule that drops an artist with these genres, and the genre that
+// matched for exclude rules. An empty rule means the artist is kept.
+func (f GenreFilter) Rejects(genres []string) (rule string, genre string) {
+	for _, term := range f.Exclude {
+		for _, g := range genres {
+			if f.matches(g, term) {
+				return "exclude:" + term, g
+			}
+		}
+	}
+
+	if len(f.Include) == 0 {
+		return "", ""
+	}
+	for _, term := range f.Include {
+		for _, g := range genres {
+			if f.matches(g, term) {
+				return "", ""
+			}
+		}
+	}
+	return "include", ""
+}
+
+func (f GenreFilter) matches(genre, term string) bool {
+	genre = strings.ToLower(genre)
+	term = strings.ToLower(strings.TrimSpace(term))
+	if f.Match == GenreMatchExact {
+		return genre == term
+	}
+	return strings.Contains(genre, term)
+}
+
+func (o SuggestionOptions) Validate() error {
+	if o.Genres != nil {
+		if err := o.Genres.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, s := range o.Seeds {
+		if err := s.Validate(); err != nil {
+			return err
+		}
+	}
+	if o.MaxSeedArtists < 0 {
+		return fmt.Errorf("maxSeedArtists can't be negative")
+	}
+	switch o.PreviouslySuggested {
+	case "", PreviouslySuggestedDownrank, PreviouslySuggestedSkip, PreviouslySuggestedInclude:
+	default:
+		return fmt.Errorf("unknown previouslySuggested %q", o.PreviouslySuggested)
+	}
+	if o.TracksPerArtist < 0 || o.TracksPerAlbum < 0 || o.MaxSuggestions < 0 {
+		return fmt.Errorf("tracksPerArtist, tracksPerAlbum and maxSuggestions can't be negative")
+	}
+	if o.SearchLimit < 0 || o.SearchLimit > maxSearchLimit {
+		return fmt.Errorf("searchLimit must be between 1 and %d", maxSearchLimit)
+	}
+	return nil
+}
+
+// WithDefaults fills in anything the request left out from the stored defaults
+func (o SuggestionOptions) WithDefaults(defaults SuggestionOptions) SuggestionOptions {
+	if o.Genres == nil {
+		o.Genres = defaults.Genres
+	}
+	if len(o.Seeds) == 0 {
+		o.Seeds = defaults.Seeds
+	}
+	if o.MaxSeedArtists == 0 {
+		o.MaxSeedArtists = defaults.MaxSeedArtists
+	}
+	if o.PreviouslySuggested == "" {
+		o.PreviouslySuggested = defaults.PreviouslySuggested
+	}
+	if o.TracksPerArtist == 0 {
+		o.TracksPerArtist = defaults.TracksPerArtist
+	}
+	if o.TracksPerAlbum == 0 {
+		o.TracksPerAlbum = defaults.TracksPerAlbum
+	}
+	if o.SearchLimit == 0 {
+		o.SearchLimit = defaults.SearchLimit
+	}
+	if o.MaxSuggestions == 0 {
+		o.MaxSuggestions = defaults.MaxSuggestions
+	}
+	if o.DeepCuts == nil {
+		o.DeepCuts = defaults.DeepCuts
+	}
+	return o
+}
+
+func (o SuggestionOptions) seeds() []SeedStrategy {
+	if len(o.Seeds) == 0 {
+		return []SeedStrategy{{Source: SeedTopArtists, Weight: 1}}
+	}
+	return o.Seeds
+}
+
+func (o SuggestionOptions) previouslySuggested() string {
+	if o.PreviouslySuggested == "" {
+		return PreviouslySuggestedDownrank
+	}
+	return o.PreviouslySuggested
+}
+
+func (o SuggestionOptions) usesSeed(source string) bool {
+	for _, s := range o.seeds() {
+		if s.Source == source {
+			return true
+		}
+	}
+	return false
+}
+
+func (o SuggestionOptions) maxSeedArtists() int {
+	if o.MaxSeedArtists == 0 {
+		return defaultMaxSeedArtists
+	}
+	return o.MaxSeedArtists
+}
+
+func (o SuggestionOptions) tracksPerArtist() int {
+	if o.TracksPerArtist == 0 {
+		return defaultTracksPerArtist
+	}
+	return o.TracksPerArtist
+}
+
+func (o SuggestionOptions) tracksPerAlbum() int {
+	if o.TracksPerAlbum == 0 {
+		return defaultTracksPerAlbum
+	}
+	return o.TracksPerAlbum
+}
+
+func (o SuggestionOptions) searchLimit() int {
+	switch {
+	case o.SearchLimit != 0:
+		return o.SearchLimit
+	case o.deepCuts():
+		return deepCutsSearchLimit
+	}
+	return defaultSearchLimit
+}
+
+func (o SuggestionOptions) deepCuts() bool {
+	return o.DeepCuts != nil && *o.DeepCuts
+}
+
+func (o SuggestionOptions) genres() GenreFilter {
+	if o.Genres == nil {
+		return GenreFilter{}
+	}
+	return *o.Genres
+}
+
+func suggestionDefaultsFile(userID string) string {
+	return "suggestion_defaults_" + userID
+}
+
+// LoadSuggestionDefaults returns the user's stored defaults, or empty options if they
+// haven't saved any
+func LoadSuggestionDefaults(userID string) (SuggestionOptions, error) {
+	if userID == "" {
+		return SuggestionOptions{}, ErrNoUserID
+	}
+	var defaults SuggestionOptions
+	err := ReadJsonObjectFromFile(&defaults, suggestionDefaultsFile(userID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return SuggestionOptions{}, nil
+	}
+	return defaults, err
+}
+
+// ResolveSuggestionOptions fills the requested options in from the user's stored defaults
+func ResolveSuggestionOptions(userID string, requested SuggestionOptions) (SuggestionOptions, error) {
+	defaults, err := LoadSuggestionDefaults(userID)
+	if err != nil {
+		return SuggestionOptions{}, err
+	}
+	return requested.WithDefaults(defaults), nil
+}
+
+func SaveSuggestionDefaults(userID string, defaults SuggestionOptions) error {
+	if userID == "" {
+		return ErrNoUserID
+	}
+	return WriteJsonObjectToFile(defaults, suggestionDefaultsFile(userID))
+}
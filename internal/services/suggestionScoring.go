@@ -0,0 +1,186 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zmb3/spotify/v2"
+)
+
+// How much each part counts towards a suggestion's score
+const (
+	suggestionAffinityWeight         = 0.5
+	suggestionPopularityWeight       = 0.25
+	suggestionAlbumDiversityWeight   = 0.15
+	suggestionAlbumFamiliarityWeight = 0.1
+
+	popularTrackThreshold = 70
+	deepCutThreshold      = 40
+)
+
+// SuggestedTrack is a suggestion along with its score and the reasons behind it
+type SuggestedTrack struct {
+	Track           spotify.FullTrack
+	Score           float64
+	ScoreComponents map[string]float64
+	Reasons         []string
+}
+
+// suggestionScorer scores picked tracks against the seeds and, when known, the library
+type suggestionScorer struct {
+	maxSeedScore   float64
+	albumTracks    map[string]int // album ID -> tracks from it in the library
+	maxAlbumTracks int
+	deepCuts       bool // less popular tracks score higher
+}
+
+func newSuggestionScorer(seeds []SeedArtist, library []spotify.FullTrack, deepCuts bool) suggestionScorer {
+	scorer := suggestionScorer{albumTracks: map[string]int{}, deepCuts: deepCuts}
+	for _, s := range seeds {
+		scorer.maxSeedScore = max(scorer.maxSeedScore, s.Score)
+	}
+
+	seen := map[spotify.ID]bool{}
+	for _, t := range library {
+		if seen[t.ID] {
+			continue
+		}
+		seen[t.ID] = true
+		albumID := t.Album.ID.String()
+		scorer.albumTracks[albumID]++
+		scorer.maxAlbumTracks = max(scorer.maxAlbumTracks, scorer.albumTracks[albumID])
+	}
+	return scorer
+}
+
+// score combines the seed artist's affinity, the track's popularity, how many tracks from
+// the same album were picked before it (albumPick starts at 1) and how much of the album
+// is already in the library
+func (s suggestionScorer) score(track spotify.FullTrack, seed SeedArtist, albumPick int) SuggestedTrack {
+	affinity := 0.0
+	if s.maxSeedScore > 0 {
+		affinity = seed.Score / s.maxSeedScore
+	}
+	popularity := float64(track.Popularity) / 100
+	if s.deepCuts {
+		popularity = 1 - popularity
+	}
+	diversity := 1 / float64(albumPick)
+
+	inLibrary := s.albumTracks[track.Album.ID.String()]
+	familiarity := 0.0
+	if s.maxAlbumTracks > 0 {
+		familiarity = float64(inLibrary) / float64(s.maxAlbumTracks)
+	}
+
+	components := map[string]float64{
+		"artistAffinity":   affinity,
+		"popularity":       popularity,
+		"albumDiversity":   diversity,
+		"albumFamiliarity": familiarity,
+	}
+
+	return SuggestedTrack{
+		Track: track,
+		Score: suggestionAffinityWeight*affinity +
+			suggestionPopularityWeight*popularity +
+			suggestionAlbumDiversityWeight*diversity +
+			suggestionAlbumFamiliarityWeight*familiarity,
+		ScoreComponents: components,
+		Reasons:         suggestionReasons(track, seed, albumPick, inLibrary, s.deepCuts),
+	}
+}
+
+// sortDeepCuts orders search results for deep cuts mode: tracks from the albums with the
+// most songs in the library first, least popular first within an album
+func (s suggestionScorer) sortDeepCuts(tracks []spotify.FullTrack) {
+	sort.SliceStable(tracks, func(i, j int) bool {
+		ai, aj := s.albumTracks[tracks[i].Album.ID.String()], s.albumTracks[tracks[j].Album.ID.String()]
+		if ai != aj {
+			return ai > aj
+		}
+		return tracks[i].Popularity < tracks[j].Popularity
+	})
+}
+
+func suggestionReasons(track spotify.FullTrack, seed SeedArtist, albumPick, albumInLibrary int, deepCuts bool) []string {
+	var reasons []string
+
+	bestRange, bestRank := "", 0
+	for tr, rank := range seed.TopRanks {
+		if bestRank == 0 || rank < bestRank || (rank == bestRank && tr < bestRange) {
+			bestRange, bestRank = tr, rank
+		}
+	}
+	if bestRank > 0 {
+		top := 25
+		if bestRank <= 10 {
+			top = 10
+		}
+		reasons = append(reasons, fmt.Sprintf("artist in your %s top %d", strings.ReplaceAll(bestRange, "_", "-"), top))
+	}
+	if seed.LibraryTracks > 0 {
+		reasons = append(reasons, fmt.Sprintf("you have %d %s by this artist from the same year", seed.LibraryTracks, plural(seed.LibraryTracks, "song")))
+	}
+	if seed.PlaylistTracks > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d %s by this artist on your seed playlist", seed.PlaylistTracks, plural(seed.PlaylistTracks, "track")))
+	}
+	if seed.Followed {
+		reasons = append(reasons, "you follow this artist")
+	}
+	if albumInLibrary > 0 {
+		reasons = append(reasons, fmt.Sprintf("you have %d %s from this album", albumInLibrary, plural(albumInLibrary, "song")))
+	}
+	if deepCuts && track.Popularity < deepCutThreshold {
+		reasons = append(reasons, fmt.Sprintf("deep cut (popularity %d)", track.Popularity))
+	} else if !deepCuts && track.Popularity >= popularTrackThreshold {
+		reasons = append(reasons, fmt.Sprintf("popular track (popularity %d)", track.Popularity))
+	}
+	if albumPick == 1 {
+		reasons = append(reasons, "first pick from this album")
+	}
+
+	return reasons
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
+
+// RankSuggestions orders suggested tracks by their suggestion score and attaches the score
+// and reasons to each. A ranking strategy chosen in the request still decides the order.
+func RankSuggestions(tracks []TrackInfo, collection *TrackCollection, opts RankingOptions, signals *RankingSignals) {
+	for i := range tracks {
+		suggested, ok := collection.suggested[tracks[i].TrackID]
+		if !ok {
+			continue
+		}
+		tracks[i].Score = suggested.Score
+		tracks[i].ScoreComponents = suggested.ScoreComponents
+		tracks[i].Reasons = suggested.Reasons
+	}
+
+	if opts.Strategy != "" {
+		RankTracks(tracks, opts, signals)
+		return
+	}
+
+	sort.SliceStable(tracks, func(i, j int) bool {
+		return tracks[i].Score > tracks[j].Score
+	})
+}
+
+// CapSuggestions keeps the best report.MaxSuggestions of the ranked tracks and counts the
+// rest in the report. It's applied after filtering to the release period, so only tracks
+// that would have been returned count towards the cap.
+func CapSuggestions(tracks []TrackInfo, report *SuggestionReport) []TrackInfo {
+	if report == nil || report.MaxSuggestions <= 0 || len(tracks) <= report.MaxSuggestions {
+		return tracks
+	}
+	report.OverMaxSuggestions = len(tracks) - report.MaxSuggestions
+	return tracks[:report.MaxSuggestions]
+}
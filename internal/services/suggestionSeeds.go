@@ -0,0 +1,341 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/zmb3/spotify/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	SeedTopArtists = "topArtists"
+	SeedLibrary    = "library"
+	SeedPlaylist   = "playlist"
+	SeedFollowed   = "followed"
+
+	defaultMaxSeedArtists = 75
+	topArtistsPerRange    = 25
+	artistLookupBatchSize = 50
+)
+
+// SeedStrategy godoc
+// @Description Where to find seed artists for suggestions. topArtists uses the user's top 25 artists in each time range; library uses artists on liked songs or playlists released in the target year; playlist uses the artists on one playlist; followed uses the artists the user follows.
+// @name SeedStrategy
+type SeedStrategy struct {
+	Source     string  `json:"source" example:"topArtists"` // topArtists, library, playlist or followed
+	Weight     float64 `json:"weight" example:"1"`          // how much this source counts towards an artist's seed score, 1 if left out
+	PlaylistID string  `json:"playlistId,omitempty"`        // for the playlist source
+}
+
+// SeedArtist godoc
+// @Description An artist suggestions are searched for, and why it was picked
+// @name SeedArtist
+type SeedArtist struct {
+	ArtistID       string         `json:"artist_id"`
+	Name           string         `json:"name"`
+	Score          float64        `json:"score"`
+	Sources        []string       `json:"sources"`
+	TopRanks       map[string]int `json:"top_ranks,omitempty"`       // time range -> rank among the user's top artists
+	LibraryTracks  int            `json:"library_tracks,omitempty"`  // liked or playlist tracks from the target year
+	PlaylistTracks int            `json:"playlist_tracks,omitempty"` // tracks on the seed playlists
+	Followed       bool           `json:"followed,omitempty"`
+}
+
+func (s SeedStrategy) Validate() error {
+	switch s.Source {
+	case SeedTopArtists, SeedLibrary, SeedFollowed:
+	case SeedPlaylist:
+		if s.PlaylistID == "" {
+			return fmt.Errorf("playlist seeds need a playlistId")
+		}
+	default:
+		return fmt.Errorf("unknown seed source %q", s.Source)
+	}
+	if s.Weight < 0 {
+		return fmt.Errorf("seed weight can't be negative")
+	}
+	return nil
+}
+
+func (s SeedStrategy) weight() float64 {
+	if s.Weight == 0 {
+		return 1
+	}
+	return s.Weight
+}
+
+type seedCandidate struct {
+	seed   SeedArtist
+	artist *spotify.FullArtist // nil until looked up, needed for genres
+}
+
+type seedCandidates map[spotify.ID]*seedCandidate
+
+func (c seedCandidates) get(id spotify.ID, name string) *seedCandidate {
+	candidate, ok := c[id]
+	if !ok {
+		candidate = &seedCandidate{seed: SeedArtist{ArtistID: id.String(), Name: name}}
+		c[id] = candidate
+	}
+	return candidate
+}
+
+// score adds a source's 0-1 score for the artist, weighted
+func (c *seedCandidate) score(source string, weight, component float64) {
+	c.seed.Score += weight * component
+	for _, s := range c.seed.Sources {
+		if s == source {
+			return
+		}
+	}
+	c.seed.Sources = append(c.seed.Sources, source)
+}
+
+// GetSeedArtists combines the seed strategies into one list of artists, highest seed score
+// first, capped at MaxSeedArtists. Artists the user blocked or the genre filter drops are
+// recorded in the report. library is the user's liked and playlist tracks, used by library
+// strategies.
+func GetSeedArtists(
+	ctx context.Context,
+	client *spotify.Client,
+	year int,
+	opts SuggestionOptions,
+	library []spotify.FullTrack,
+	feedback SuggestionFeedback,
+	report *SuggestionReport,
+) ([]SeedArtist, error) {
+	candidates := seedCandidates{}
+
+	for _, strategy := range opts.seeds() {
+		var err error
+		switch strategy.Source {
+		case SeedTopArtists:
+			err = addTopArtistSeeds(ctx, client, strategy.weight(), candidates)
+		case SeedLibrary:
+			addLibrarySeeds(FilterTracksFromYear(library, year), strategy.weight(), candidates)
+		case SeedPlaylist:
+			err = addPlaylistSeeds(ctx, client, strategy.PlaylistID, strategy.weight(), candidates)
+		case SeedFollowed:
+			err = addFollowedSeeds(ctx, client, strategy.weight(), candidates)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sorted := make([]*seedCandidate, 0, len(candidates))
+	for _, c := range candidates {
+		if _, blocked := feedback.BlockedArtists[c.seed.ArtistID]; blocked {
+			report.DroppedArtists = append(report.DroppedArtists, DroppedArtist{
+				ArtistID: c.seed.ArtistID,
+				Name:     c.seed.Name,
+				Rule:     "blocked",
+			})
+			continue
+		}
+		sorted = append(sorted, c)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].seed.Score != sorted[j].seed.Score {
+			return sorted[i].seed.Score > sorted[j].seed.Score
+		}
+		return sorted[i].seed.Name < sorted[j].seed.Name
+	})
+
+	// Look genres up a batch at a time, only as far down the list as the cap needs
+	genres := opts.genres()
+	maxSeeds := opts.maxSeedArtists()
+	seeds := make([]SeedArtist, 0, min(maxSeeds, len(sorted)))
+	for start := 0; start < len(sorted) && len(seeds) < maxSeeds; start += artistLookupBatchSize {
+		batch := sorted[start:min(start+artistLookupBatchSize, len(sorted))]
+		if err := lookUpSeedArtists(ctx, client, batch); err != nil {
+			return nil, err
+		}
+
+		for _, c := range batch {
+			if len(seeds) >= maxSeeds {
+				break
+			}
+			if c.artist != nil {
+				if rule, genre := genres.Rejects(c.artist.Genres); rule != "" {
+					report.DroppedArtists = append(report.DroppedArtists, DroppedArtist{
+						ArtistID: c.seed.ArtistID,
+						Name:     c.seed.Name,
+						Rule:     rule,
+						Genre:    genre,
+					})
+					continue
+				}
+			}
+			seeds = append(seeds, c.seed)
+		}
+	}
+
+	zap.L().Info("Picked seed artists",
+		zap.Int("candidates", len(candidates)),
+		zap.Int("seeds", len(seeds)),
+		zap.Int("dropped", len(report.DroppedArtists)),
+	)
+	return seeds, nil
+}
+
+// addTopArtistSeeds scores the user's top artists by rank, averaged over the time ranges
+func addTopArtistSeeds(ctx context.Context, client *spotify.Client, weight float64, candidates seedCandidates) error {
+	allSpotifyTimeRanges := []spotify.Range{
+		spotify.ShortTermRange,
+		spotify.MediumTermRange,
+		spotify.LongTermRange,
+	}
+
+	byRange, err := getTopArtistsInRanges(ctx, client, allSpotifyTimeRanges, topArtistsPerRange)
+	if err != nil {
+		return err
+	}
+
+	for _, tr := range allSpotifyTimeRanges {
+		artists := byRange[tr]
+		for i, artist := range artists {
+			c := candidates.get(artist.ID, artist.Name)
+			c.artist = &artists[i]
+			if c.seed.TopRanks == nil {
+				c.seed.TopRanks = map[string]int{}
+			}
+			c.seed.TopRanks[string(tr)] = i + 1
+
+			rankScore := float64(topArtistsPerRange-i) / topArtistsPerRange
+			c.score(SeedTopArtists, weight, rankScore/float64(len(allSpotifyTimeRanges)))
+		}
+	}
+	return nil
+}
+
+// addLibrarySeeds scores artists by how many of the year's tracks in the library are theirs
+func addLibrarySeeds(tracks []spotify.FullTrack, weight float64, candidates seedCandidates) {
+	counts := countTracksByArtist(tracks)
+	maxCount := maxArtistCount(counts)
+	for id, count := range counts {
+		c := candidates.get(id, count.name)
+		c.seed.LibraryTracks += count.tracks
+		c.score(SeedLibrary, weight, float64(count.tracks)/float64(maxCount))
+	}
+}
+
+func addPlaylistSeeds(ctx context.Context, client *spotify.Client, playlistID string, weight float64, candidates seedCandidates) error {
+	tracks, err := GetAllPlaylistTracks(ctx, client, spotify.SimplePlaylist{ID: spotify.ID(playlistID), Name: playlistID})
+	if err != nil {
+		return err
+	}
+
+	counts := countTracksByArtist(tracks)
+	maxCount := maxArtistCount(counts)
+	for id, count := range counts {
+		c := candidates.get(id, count.name)
+		c.seed.PlaylistTracks += count.tracks
+		c.score(SeedPlaylist, weight, float64(count.tracks)/float64(maxCount))
+	}
+	return nil
+}
+
+func addFollowedSeeds(ctx context.Context, client *spotify.Client, weight float64, candidates seedCandidates) error {
+	followed, err := GetFollowedArtists(ctx, client)
+	if err != nil {
+		return err
+	}
+	for i, artist := range followed {
+		c := candidates.get(artist.ID, artist.Name)
+		c.artist = &followed[i]
+		c.seed.Followed = true
+		c.score(SeedFollowed, weight, 1)
+	}
+	return nil
+}
+
+// GetFollowedArtists pages through every artist the user follows
+func GetFollowedArtists(ctx context.Context, client *spotify.Client) ([]spotify.FullArtist, error) {
+	var all []spotify.FullArtist
+	var opts []spotify.RequestOption
+
+	for {
+		page, err := client.CurrentUsersFollowedArtists(ctx, append(opts, spotify.Limit(50))...)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page.Artists...)
+
+		if page.Cursor.After == "" || len(page.Artists) == 0 {
+			break
+		}
+		opts = []spotify.RequestOption{spotify.After(page.Cursor.After)}
+	}
+
+	zap.L().Info("Fetched followed artists", zap.Int("count", len(all)))
+	return all, nil
+}
+
+// lookUpSeedArtists fetches full artists (for their genres) for candidates that only came
+// from tracks
+func lookUpSeedArtists(ctx context.Context, client *spotify.Client, batch []*seedCandidate) error {
+	var ids []spotify.ID
+	for _, c := range batch {
+		if c.artist == nil {
+			ids = append(ids, spotify.ID(c.seed.ArtistID))
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	artists, err := client.GetArtists(ctx, ids...)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]*spotify.FullArtist, len(artists))
+	for _, a := range artists {
+		if a != nil {
+			byID[a.ID.String()] = a
+		}
+	}
+	for _, c := range batch {
+		if c.artist == nil {
+			c.artist = byID[c.seed.ArtistID]
+		}
+	}
+	return nil
+}
+
+type artistTrackCount struct {
+	name   string
+	tracks int
+}
+
+// countTracksByArtist counts each track once for every artist credited on it
+func countTracksByArtist(tracks []spotify.FullTrack) map[spotify.ID]artistTrackCount {
+	counts := map[spotify.ID]artistTrackCount{}
+	seen := map[spotify.ID]bool{}
+	for _, t := range tracks {
+		if seen[t.ID] {
+			continue
+		}
+		seen[t.ID] = true
+		for _, a := range t.Artists {
+			if a.ID == "" {
+				continue
+			}
+			count := counts[a.ID]
+			count.name = a.Name
+			count.tracks++
+			counts[a.ID] = count
+		}
+	}
+	return counts
+}
+
+func maxArtistCount(counts map[spotify.ID]artistTrackCount) int {
+	maxCount := 1
+	for _, c := range counts {
+		maxCount = max(maxCount, c.tracks)
+	}
+	return maxCount
+}
@@ -4,98 +4,105 @@ import (
 	"context"
 	"fmt"
 	"sort"
-	"strings"
 
 	"github.com/zmb3/spotify/v2"
 	"go.uber.org/zap"
 )
 
-func GetSuggestedArtists(
+// Spotify returns at most 50 search results per page
+const searchPageSize = 50
+
+// GetSuggestedTracksFromYear searches each seed artist's tracks from the year and scores
+// them, best first. Tracks the user has saved or disliked are never suggested, and tracks
+// suggested to them before are handled as the options say. The user's feedback also blocks
+// seed artists and gives artists they've liked suggestions from more tracks. library is
+// the user's liked and playlist tracks if the caller already has them, used for library
+// seeds, album familiarity and dropping other versions of songs they already have. When
+// it's nil it's fetched, using the playlists picked by selection.
+func GetSuggestedTracksFromYear(
 	ctx context.Context,
 	client *spotify.Client,
-) ([]spotify.FullArtist, error) {
-	// Slice to store all unique artists
-	var allSuggestedArtists []spotify.FullArtist
-	seen := make(map[string]bool) // track artist IDs to avoid duplicates
-
-	allSpotifyTimeRanges := []spotify.Range{
-		spotify.ShortTermRange,
-		spotify.MediumTermRange,
-		spotify.LongTermRange,
-	}
+	userID string,
+	year int,
+	opts SuggestionOptions,
+	selection PlaylistSelection,
+	library []spotify.FullTrack,
+) ([]SuggestedTrack, *SuggestionReport, error) {
+	var allTrackSuggestions []SuggestedTrack
+	seenTracks := make(map[string]bool)
+	report := NewSuggestionReport()
+	// Capped by the caller once the tracks outside the release period are dropped
+	report.MaxSuggestions = opts.MaxSuggestions
 
-	for _, tr := range allSpotifyTimeRanges {
-		page, err := client.CurrentUsersTopArtists(ctx, spotify.Timerange(tr), spotify.Limit(25))
+	// Every score needs the library to know which albums the user likes
+	if library == nil {
+		lib, err := GetLibrary(ctx, client, selection)
 		if err != nil {
-			return nil, err
-		}
-
-		for _, artist := range page.Artists {
-
-			// Exclude artists with "classical" in any of their genres
-			exclude := false
-			for _, genre := range artist.Genres {
-				if strings.Contains(strings.ToLower(genre), "classical") {
-					exclude = true
-					break
-				}
-			}
-			if exclude {
-				continue
-			}
-
-			if !seen[artist.ID.String()] {
-				seen[artist.ID.String()] = true
-				allSuggestedArtists = append(allSuggestedArtists, artist)
-			}
+			return nil, nil, err
 		}
+		library = append(lib.Liked, lib.OnPlaylists...)
 	}
 
-	zap.L().Info("Found user's top artists", zap.Int("count", len(allSuggestedArtists)))
-	return allSuggestedArtists, nil
-}
+	feedback, err := LoadSuggestionFeedback(userID)
+	if err != nil {
+		return nil, nil, err
+	}
 
-func GetSuggestedTracksFromYear(
-	ctx context.Context,
-	client *spotify.Client,
-	year int,
-) ([]spotify.FullTrack, error) {
-	var allTrackSuggestions []spotify.FullTrack
-	seenTracks := make(map[string]bool)
+	seeds, err := GetSeedArtists(ctx, client, year, opts, library, feedback, report)
+	if err != nil {
+		return nil, nil, err
+	}
+	report.Seeds = seeds
+	scorer := newSuggestionScorer(seeds, library, opts.deepCuts())
+	perAlbum := opts.tracksPerAlbum()
+	libraryVersions := newVersionIndex(library)
 
-	suggestedArtists, err := GetSuggestedArtists(ctx, client)
+	history, err := loadSuggestionHistory(userID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	previouslySuggested := opts.previouslySuggested()
 
-	for _, artist := range suggestedArtists {
+	for _, artist := range seeds {
 		zap.L().Info("Getting suggested tracks from year for artist", zap.Int("year", year), zap.String("artist", artist.Name))
 
-		query := fmt.Sprintf("year:%d artist:%s", year, artist.Name)
-		sr, err := client.Search(ctx, query, spotify.SearchTypeTrack, spotify.Limit(50))
+		query := fmt.Sprintf("year:%d artist:%s", year, quoteSearchTerm(artist.Name))
+		found, err := searchTracks(ctx, client, query, opts.searchLimit())
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		found = keepSeedArtistTracks(found, artist, report)
+		found, unplayable := RemoveUnplayable(found)
+		report.SkippedUnplayable += unplayable
+		found = removeOtherVersions(found, libraryVersions, report)
 
-		tracks := sr.Tracks.Tracks
-		// Sort by popularity descending
-		sort.Slice(tracks, func(i, j int) bool {
-			return tracks[i].Popularity > tracks[j].Popularity
-		})
+		tracks, err := removeKnownTracks(ctx, client, found, history, feedback, previouslySuggested, report)
+		if err != nil {
+			return nil, nil, err
+		}
+		if opts.deepCuts() {
+			scorer.sortDeepCuts(tracks)
+		} else {
+			// Sort by popularity descending
+			sort.Slice(tracks, func(i, j int) bool {
+				return tracks[i].Popularity > tracks[j].Popularity
+			})
+		}
 
-		artistTracks := make([]spotify.FullTrack, 0, 5)
+		slots := feedback.artistSlots(artist.ArtistID, opts.tracksPerArtist())
+		artistTracks := make([]spotify.FullTrack, 0, slots)
 		albumCount := make(map[string]int)  // albumID -> number of tracks included for this artist
 		albumAdded := make(map[string]bool) // keep track if album has been used for diversity
 
 		// Step 1: try to pick tracks from different albums first
 		for _, track := range tracks {
-			if len(artistTracks) >= 5 {
+			if len(artistTracks) >= slots {
 				break
 			}
 			trackID := track.ID.String()
 			albumID := track.Album.ID.String()
 
-			if seenTracks[trackID] || albumCount[albumID] >= 3 {
+			if seenTracks[trackID] || albumCount[albumID] >= perAlbum {
 				continue
 			}
 
@@ -108,15 +115,15 @@ func GetSuggestedTracksFromYear(
 			}
 		}
 
-		// Step 2: fill remaining slots with most popular tracks (even from albums already added) respecting 3 per album
+		// Step 2: fill remaining slots in the same order (even from albums already added) respecting the per-album quota
 		for _, track := range tracks {
-			if len(artistTracks) >= 5 {
+			if len(artistTracks) >= slots {
 				break
 			}
 			trackID := track.ID.String()
 			albumID := track.Album.ID.String()
 
-			if seenTracks[trackID] || albumCount[albumID] >= 3 {
+			if seenTracks[trackID] || albumCount[albumID] >= perAlbum {
 				continue
 			}
 
@@ -125,8 +132,89 @@ func GetSuggestedTracksFromYear(
 			albumCount[albumID]++
 		}
 
-		allTrackSuggestions = append(allTrackSuggestions, artistTracks...)
+		albumPicks := make(map[string]int)
+		for _, track := range artistTracks {
+			albumPicks[track.Album.ID.String()]++
+			suggested := scorer.score(track, artist, albumPicks[track.Album.ID.String()])
+			if entry, seen := history[track.ID.String()]; seen && previouslySuggested == PreviouslySuggestedDownrank {
+				suggested.downrank(entry)
+				report.DownrankedPreviouslySuggested++
+			}
+			allTrackSuggestions = append(allTrackSuggestions, suggested)
+		}
+	}
+
+	sort.SliceStable(allTrackSuggestions, func(i, j int) bool {
+		return allTrackSuggestions[i].Score > allTrackSuggestions[j].Score
+	})
+
+	return allTrackSuggestions, report, nil
+}
+
+// searchTracks pages through track search results until it has limit of them or runs out
+func searchTracks(ctx context.Context, client *spotify.Client, query string, limit int) ([]spotify.FullTrack, error) {
+	var tracks []spotify.FullTrack
+	for len(tracks) < limit {
+		opts := append(marketOptions(ctx), spotify.Limit(min(searchPageSize, limit-len(tracks))), spotify.Offset(len(tracks)))
+		sr, err := client.Search(ctx, query, spotify.SearchTypeTrack, opts...)
+		if err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, sr.Tracks.Tracks...)
+
+		if len(sr.Tracks.Tracks) == 0 || len(tracks) >= int(sr.Tracks.Total) {
+			break
+		}
 	}
+	return tracks, nil
+}
 
-	return allTrackSuggestions, nil
+// keepSeedArtistTracks drops search hits that aren't by the seed artist. Tracks are matched
+// on artist ID, falling back to the artist name ignoring case and accents for artists
+// Spotify lists under more than one ID.
+func keepSeedArtistTracks(tracks []spotify.FullTrack, seed SeedArtist, report *SuggestionReport) []spotify.FullTrack {
+	seedName := NormaliseName(seed.Name)
+	kept := make([]spotify.FullTrack, 0, len(tracks))
+	for _, t := range tracks {
+		if byArtist(t, seed.ArtistID, seedName) {
+			kept = append(kept, t)
+			continue
+		}
+		report.RejectedSearchResults++
+	}
+	if rejected := len(tracks) - len(kept); rejected > 0 {
+		zap.L().Debug("Rejected search results by other artists", zap.String("artist", seed.Name), zap.Int("rejected", rejected))
+	}
+	return kept
+}
+
+func byArtist(track spotify.FullTrack, artistID, normalisedName string) bool {
+	for _, a := range track.Artists {
+		if a.ID.String() == artistID {
+			return true
+		}
+	}
+	for _, a := range track.Artists {
+		if normalisedName != "" && NormaliseName(a.Name) == normalisedName {
+			return true
+		}
+	}
+	return false
+}
+
+// removeOtherVersions keeps the most popular version of each song in the search results,
+// and drops songs the library already has a version of
+func removeOtherVersions(tracks []spotify.FullTrack, library versionIndex, report *SuggestionReport) []spotify.FullTrack {
+	tracks, duplicates := DedupeTracks(tracks, DedupeMostPopular)
+	report.SkippedOtherVersions += duplicates
+
+	kept := make([]spotify.FullTrack, 0, len(tracks))
+	for _, t := range tracks {
+		if library.contains(t) {
+			report.SkippedOtherVersions++
+			continue
+		}
+		kept = append(kept, t)
+	}
+	return kept
 }
@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/go-analyze/charts"
+	"github.com/zmb3/spotify/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	TopArtistsChartBars  = "bars"
+	TopArtistsChartSlope = "slope"
+
+	TrendRising  = "rising"
+	TrendFalling = "falling"
+	TrendSteady  = "steady"
+
+	defaultTopArtistsCount = 10
+	// Ranks are looked up this far down each range, so artists can be placed in ranges
+	// they're not in the top N for
+	topArtistsRankDepth = 50
+	// Places an artist has to move between long and short term to count as rising or falling
+	trendRankChange = 5
+)
+
+// topArtistRanges are the time ranges, oldest listening first
+var topArtistRanges = []spotify.Range{spotify.LongTermRange, spotify.MediumTermRange, spotify.ShortTermRange}
+
+// TopArtistsOptions picks how many artists to chart and how. Zero values use the defaults.
+type TopArtistsOptions struct {
+	Count int    // artists shown, 10 by default
+	Chart string // bars (default) or slope
+}
+
+// TopArtistRank godoc
+// @Description An artist's rank among the user's top artists in each time range, null when outside the top 50
+// @name TopArtistRank
+type TopArtistRank struct {
+	ArtistID   string `json:"artist_id"`
+	Name       string `json:"name"`
+	LongTerm   *int   `json:"long_term"`
+	MediumTerm *int   `json:"medium_term"`
+	ShortTerm  *int   `json:"short_term"`
+	Change     int    `json:"change"`                 // places climbed from long to short term, counting unranked as 51st
+	Trend      string `json:"trend" example:"rising"` // rising or falling when change is at least 5 places, otherwise steady
+}
+
+// TopArtistsData godoc
+// @Description The user's top artists across the time ranges. Artists come from the top N of any range, best ranked first.
+// @name TopArtistsData
+type TopArtistsData struct {
+	Title   string          `json:"title"`
+	Ranges  []string        `json:"ranges" example:"long_term,medium_term,short_term"` // the order ranks are drawn in
+	Artists []TopArtistRank `json:"artists"`
+}
+
+func (o TopArtistsOptions) Validate() error {
+	switch o.Chart {
+	case "", TopArtistsChartBars, TopArtistsChartSlope:
+	default:
+		return fmt.Errorf("unknown chart %q, expected bars or slope", o.Chart)
+	}
+	if o.Count < 0 || o.Count > topArtistsRankDepth {
+		return fmt.Errorf("count must be between 1 and %d", topArtistsRankDepth)
+	}
+	return nil
+}
+
+func (o TopArtistsOptions) count() int {
+	if o.Count == 0 {
+		return defaultTopArtistsCount
+	}
+	return o.Count
+}
+
+// GetTopArtistsByRange fetches the user's top 50 artists in each time range
+func GetTopArtistsByRange(ctx context.Context, client *spotify.Client) (map[spotify.Range][]spotify.FullArtist, error) {
+	return getTopArtistsInRanges(ctx, client, topArtistRanges, topArtistsRankDepth)
+}
+
+// AggregateTopArtists ranks the artists in the top N of any range across every range, and
+// works out which are rising or falling
+func AggregateTopArtists(byRange map[spotify.Range][]spotify.FullArtist, graphTitle string, opts TopArtistsOptions) TopArtistsData {
+	data := TopArtistsData{Title: graphTitle, Artists: []TopArtistRank{}}
+	for _, tr := range topArtistRanges {
+		data.Ranges = append(data.Ranges, string(tr))
+	}
+
+	ranks := map[spotify.ID]*TopArtistRank{}
+	var seen []*TopArtistRank // in the order first seen, so ties sort the same every time
+	for _, tr := range topArtistRanges {
+		for i, artist := range byRange[tr] {
+			entry, ok := ranks[artist.ID]
+			if !ok {
+				entry = &TopArtistRank{ArtistID: artist.ID.String(), Name: artist.Name}
+				ranks[artist.ID] = entry
+				seen = append(seen, entry)
+			}
+			rank := i + 1
+			switch tr {
+			case spotify.LongTermRange:
+				entry.LongTerm = &rank
+			case spotify.MediumTermRange:
+				entry.MediumTerm = &rank
+			case spotify.ShortTermRange:
+				entry.ShortTerm = &rank
+			}
+		}
+	}
+
+	var candidates []*TopArtistRank
+	for _, entry := range seen {
+		if entry.bestRank() <= opts.count() {
+			candidates = append(candidates, entry)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if a, b := candidates[i].bestRank(), candidates[j].bestRank(); a != b {
+			return a < b
+		}
+		return rankOrUnranked(candidates[i].ShortTerm) < rankOrUnranked(candidates[j].ShortTerm)
+	})
+	for _, entry := range candidates[:min(len(candidates), opts.count())] {
+		entry.Change = rankOrUnranked(entry.LongTerm) - rankOrUnranked(entry.ShortTerm)
+		switch {
+		case entry.Change >= trendRankChange:
+			entry.Trend = TrendRising
+		case entry.Change <= -trendRankChange:
+			entry.Trend = TrendFalling
+		default:
+			entry.Trend = TrendSteady
+		}
+		data.Artists = append(data.Artists, *entry)
+	}
+	return data
+}
+
+func (r *TopArtistRank) ranks() []*int {
+	return []*int{r.LongTerm, r.MediumTerm, r.ShortTerm}
+}
+
+func (r *TopArtistRank) bestRank() int {
+	best := topArtistsRankDepth + 1
+	for _, rank := range r.ranks() {
+		best = min(best, rankOrUnranked(rank))
+	}
+	return best
+}
+
+// rankOrUnranked is the rank, or just past the bottom of the lists when there isn't one
+func rankOrUnranked(rank *int) int {
+	if rank == nil {
+		return topArtistsRankDepth + 1
+	}
+	return *rank
+}
+
+// trendLabel is the artist's name with how many places they've moved, for rising and
+// falling artists
+func (r TopArtistRank) trendLabel() string {
+	if r.Trend == TrendSteady {
+		return r.Name
+	}
+	return fmt.Sprintf("%s (%+d)", r.Name, r.Change)
+}
+
+// rankHeight turns a rank into a height, so better ranks are drawn higher
+func rankHeight(rank *int) float64 {
+	if rank == nil {
+		return charts.GetNullValue()
+	}
+	return float64(topArtistsRankDepth + 1 - *rank)
+}
+
+func formatRankHeight(height float64) string {
+	return fmt.Sprintf("#%d", topArtistsRankDepth+1-int(math.Round(height)))
+}
+
+// RenderTopArtists draws the ranks as grouped bars, one group per artist, or as a slope
+// chart with a line per artist coloured by whether they're rising or falling
+func RenderTopArtists(data TopArtistsData, style string, chartOpts ChartOptions) ([]byte, error) {
+	if len(data.Artists) == 0 {
+		return nil, fmt.Errorf("no top artists found")
+	}
+	rangeNames := []string{"Long term", "Medium term", "Short term"}
+
+	painter := charts.NewPainter(chartOpts.painterOptions())
+	var err error
+	if style == TopArtistsChartSlope {
+		values := make([][]float64, len(data.Artists))
+		names := make([]string, len(data.Artists))
+		colors := make([]charts.Color, len(data.Artists))
+		worst := 1
+		for i, a := range data.Artists {
+			for _, rank := range a.ranks() {
+				values[i] = append(values[i], rankHeight(rank))
+				if rank != nil {
+					worst = max(worst, *rank)
+				}
+			}
+			names[i] = a.trendLabel()
+			switch a.Trend {
+			case TrendRising:
+				colors[i] = charts.ColorGreenAlt3
+			case TrendFalling:
+				colors[i] = charts.ColorRed
+			default:
+				colors[i] = charts.ColorGray
+			}
+		}
+
+		opt := charts.NewLineChartOptionWithData(values)
+		opt.Theme = chartOpts.theme().WithSeriesColors(colors)
+		opt.Title.Text = data.Title
+		opt.Title.FontStyle = chartOpts.font(chartTitleFontScale)
+		opt.XAxis.Labels = rangeNames
+		opt.XAxis.LabelFontStyle = chartOpts.font(1)
+		opt.YAxis[0].Min = charts.Ptr(rankHeight(&worst))
+		opt.YAxis[0].Max = charts.Ptr(float64(topArtistsRankDepth))
+		opt.YAxis[0].RangeValuePaddingScale = charts.Ptr(0.0)
+		opt.YAxis[0].Unit = 1
+		opt.YAxis[0].LabelFontStyle = chartOpts.font(1)
+		opt.ValueFormatter = formatRankHeight
+		opt.Legend.SeriesNames = names
+		opt.Legend.Offset = charts.OffsetStr{Left: charts.PositionCenter, Top: charts.PositionBottom}
+		opt.Legend.FontStyle = chartOpts.font(1)
+		err = painter.LineChart(opt)
+	} else {
+		values := make([][]float64, len(topArtistRanges))
+		labels := make([]string, len(data.Artists))
+		for i, a := range data.Artists {
+			for r, rank := range a.ranks() {
+				height := rankHeight(rank)
+				if rank == nil {
+					height = 0
+				}
+				values[r] = append(values[r], height)
+			}
+			labels[i] = a.trendLabel()
+		}
+
+		opt := charts.NewBarChartOptionWithData(values)
+		opt.Theme = chartOpts.theme()
+		opt.Title.Text = data.Title
+		opt.Title.FontStyle = chartOpts.font(chartTitleFontScale)
+		opt.XAxis.Labels = labels
+		opt.XAxis.LabelRotation = math.Pi / 4
+		opt.XAxis.LabelFontStyle = chartOpts.font(1)
+		opt.YAxis[0].Show = charts.Ptr(false)
+		opt.YAxis[0].Min = charts.Ptr(0.0)
+		opt.Legend.SeriesNames = rangeNames
+		opt.Legend.Offset = charts.OffsetRight
+		opt.Legend.FontStyle = chartOpts.font(1)
+		for i := range opt.SeriesList {
+			opt.SeriesList[i].Label.Show = charts.Ptr(true)
+			opt.SeriesList[i].Label.FontStyle = chartOpts.font(1)
+			opt.SeriesList[i].Label.LabelFormatter = func(_ int, _ string, height float64) (string, *charts.LabelStyle) {
+				if height == 0 {
+					return "", nil
+				}
+				return formatRankHeight(height), nil
+			}
+		}
+		err = painter.BarChart(opt)
+	}
+	if err != nil {
+		zap.L().Error("Failed to build top artists chart", zap.Error(err))
+		return nil, err
+	}
+
+	return painter.Bytes()
+}
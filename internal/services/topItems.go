@@ -37,3 +37,37 @@ func GetTopTracks(
 
 	return allTracks, nil
 }
+
+// GetTopArtists returns the user's top artists for the time range, best first. Spotify
+// returns at most 50.
+func GetTopArtists(
+	ctx context.Context,
+	client *spotify.Client,
+	timeRange spotify.Range,
+	limit int,
+) ([]spotify.FullArtist, error) {
+	page, err := client.CurrentUsersTopArtists(ctx, spotify.Timerange(timeRange), spotify.Limit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching top artists: %w", err)
+	}
+	return page.Artists, nil
+}
+
+// getTopArtistsInRanges fetches the user's top artists for each of the time ranges, up to
+// limit per range
+func getTopArtistsInRanges(
+	ctx context.Context,
+	client *spotify.Client,
+	ranges []spotify.Range,
+	limit int,
+) (map[spotify.Range][]spotify.FullArtist, error) {
+	byRange := make(map[spotify.Range][]spotify.FullArtist, len(ranges))
+	for _, tr := range ranges {
+		artists, err := GetTopArtists(ctx, client, tr, limit)
+		if err != nil {
+			return nil, err
+		}
+		byRange[tr] = artists
+	}
+	return byRange, nil
+}
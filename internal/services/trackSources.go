@@ -0,0 +1,113 @@
+package services
+
+import (
+	"github.com/zmb3/spotify/v2"
+)
+
+// TrackProvenance godoc
+// @Description Where a track was found while building the response
+// @name TrackProvenance
+type TrackProvenance struct {
+	Liked       bool     `json:"liked"`
+	TopTracks   []string `json:"top_tracks,omitempty" example:"short_term"` // time ranges the track is a top track in
+	PlaylistIDs []string `json:"playlist_ids,omitempty"`
+	Suggested   bool     `json:"suggested"`
+}
+
+// TrackCollection is a set of fetched tracks along with where each one came from. The same
+// track can be added from several sources; Tracks keeps every copy so callers can still
+// count duplicates.
+type TrackCollection struct {
+	Tracks    []spotify.FullTrack
+	Playlists []spotify.SimplePlaylist // playlists added, in the order they were walked
+	// How the suggested tracks were picked, when the collection has any
+	SuggestionReport *SuggestionReport
+	sources          map[string]*TrackProvenance
+	suggested        map[string]SuggestedTrack
+	// Kept with their added dates so ranking doesn't fetch them again
+	likedItems    []spotify.SavedTrack
+	playlistItems [][]spotify.PlaylistItem
+}
+
+func NewTrackCollection() *TrackCollection {
+	return &TrackCollection{
+		sources:   map[string]*TrackProvenance{},
+		suggested: map[string]SuggestedTrack{},
+	}
+}
+
+// AddLiked adds the user's saved tracks and returns them
+func (c *TrackCollection) AddLiked(saved []spotify.SavedTrack) []spotify.FullTrack {
+	c.likedItems = append(c.likedItems, saved...)
+	tracks := make([]spotify.FullTrack, 0, len(saved))
+	for _, t := range saved {
+		tracks = append(tracks, t.FullTrack)
+		c.provenance(t.ID.String()).Liked = true
+	}
+	c.Tracks = append(c.Tracks, tracks...)
+	return tracks
+}
+
+// AddPlaylist adds the playlist's tracks, skipping episodes and unavailable tracks, and
+// returns them
+func (c *TrackCollection) AddPlaylist(playlist spotify.SimplePlaylist, items []spotify.PlaylistItem) []spotify.FullTrack {
+	c.Playlists = append(c.Playlists, playlist)
+	c.playlistItems = append(c.playlistItems, items)
+
+	playlistID := playlist.ID.String()
+	tracks := make([]spotify.FullTrack, 0, len(items))
+	for _, item := range items {
+		if item.Track.Track == nil {
+			continue
+		}
+		t := *item.Track.Track
+		tracks = append(tracks, t)
+		p := c.provenance(t.ID.String())
+		if n := len(p.PlaylistIDs); n == 0 || p.PlaylistIDs[n-1] != playlistID {
+			p.PlaylistIDs = append(p.PlaylistIDs, playlistID)
+		}
+	}
+	c.Tracks = append(c.Tracks, tracks...)
+	return tracks
+}
+
+// UsePlaylists records the playlists looked at for anything other than their tracks, when
+// none were added, so they can still be listed as the playlists used
+func (c *TrackCollection) UsePlaylists(playlists []spotify.SimplePlaylist) {
+	if len(c.Playlists) == 0 {
+		c.Playlists = playlists
+	}
+}
+
+func (c *TrackCollection) AddSuggested(suggestions []SuggestedTrack) {
+	for _, s := range suggestions {
+		id := s.Track.ID.String()
+		c.Tracks = append(c.Tracks, s.Track)
+		c.provenance(id).Suggested = true
+		c.suggested[id] = s
+	}
+}
+
+// MarkTopTracks records that the tracks are top tracks in the time range, without adding
+// them to the collection
+func (c *TrackCollection) MarkTopTracks(timeRange string, trackIDs []string) {
+	for _, id := range trackIDs {
+		p := c.provenance(id)
+		p.TopTracks = append(p.TopTracks, timeRange)
+	}
+}
+
+// Provenance returns where the track was found, or nil if it isn't in the collection. The
+// result is shared, so sources added later show up in it too.
+func (c *TrackCollection) Provenance(trackID string) *TrackProvenance {
+	return c.sources[trackID]
+}
+
+func (c *TrackCollection) provenance(trackID string) *TrackProvenance {
+	p, ok := c.sources[trackID]
+	if !ok {
+		p = &TrackProvenance{}
+		c.sources[trackID] = p
+	}
+	return p
+}
@@ -2,14 +2,19 @@ package services
 
 import (
 	"context"
-	"strconv"
+	"fmt"
 
 	"github.com/zmb3/spotify/v2"
 	"go.uber.org/zap"
 )
 
+const (
+	TrackFieldsFull    = "full"
+	TrackFieldsCompact = "compact"
+)
+
 // TrackInfo godoc
-// @Description Short track info returned by year endpoints
+// @Description Track info returned by year endpoints. The fields after popularity are left out when the compact form is requested.
 // @name TrackInfo
 type TrackInfo struct {
 	TrackID     string   `json:"track_id"`
@@ -18,19 +23,56 @@ type TrackInfo struct {
 	AlbumName   string   `json:"album_name"`
 	ReleaseDate string   `json:"release_date"`
 	Popularity  int      `json:"popularity"`
+	// False when the track can't be played in the user's country
+	Playable *bool `json:"playable,omitempty"`
+	// Score and its parts from the ranking strategy used to order the results
+	Score           float64            `json:"score,omitempty"`
+	ScoreComponents map[string]float64 `json:"score_components,omitempty"`
+	// Why a suggested track was suggested
+	Reasons []string `json:"reasons,omitempty"`
+
+	DurationMs  int              `json:"duration_ms,omitempty"`
+	Explicit    *bool            `json:"explicit,omitempty"`
+	ISRC        string           `json:"isrc,omitempty"`
+	TrackNumber int              `json:"track_number,omitempty"`
+	ExternalURL string           `json:"external_url,omitempty"`
+	AlbumID     string           `json:"album_id,omitempty"`
+	AlbumType   string           `json:"album_type,omitempty"`
+	ArtistIDs   []string         `json:"artist_ids,omitempty"`
+	Provenance  *TrackProvenance `json:"provenance,omitempty"`
+	// The track asked for, when Spotify swapped in a version playable in the user's country
+	LinkedFromID string `json:"linked_from_id,omitempty"`
+}
+
+// ValidateTrackFields checks a field selection; empty means the compact form
+func ValidateTrackFields(fields string) error {
+	switch fields {
+	case "", TrackFieldsFull, TrackFieldsCompact:
+		return nil
+	default:
+		return fmt.Errorf("unknown fields %q, expected %q or %q", fields, TrackFieldsFull, TrackFieldsCompact)
+	}
 }
 
 func FilterTracksFromYear(tracks []spotify.FullTrack, year int) []spotify.FullTrack {
+	return FilterTracksFromPeriod(tracks, YearPeriod(year))
+}
+
+func FilterTracksFromPeriod(tracks []spotify.FullTrack, period ReleasePeriod) []spotify.FullTrack {
 	seen := make(map[string]bool)
 	result := make([]spotify.FullTrack, 0)
+	unknownMonth := 0
 
 	for _, track := range tracks {
-		if track.Album.ReleaseDate == "" {
+		releaseDate, ok := TrackReleaseDate(track)
+		if !ok {
 			continue
 		}
 
-		releaseYear, err := strconv.Atoi(track.Album.ReleaseDate[:4])
-		if err != nil || releaseYear != year {
+		if !period.Contains(releaseDate) {
+			if releaseDate.Year == period.Year && !releaseDate.HasMonth() {
+				unknownMonth++
+			}
 			continue
 		}
 
@@ -44,36 +86,62 @@ func FilterTracksFromYear(tracks []spotify.FullTrack, year int) []spotify.FullTr
 		result = append(result, track)
 	}
 
+	if unknownMonth > 0 {
+		zap.L().Debug("Skipped tracks with only a release year", zap.String("period", period.String()), zap.Int("count", unknownMonth))
+	}
+
 	return result
 }
 
-func GetShortTrackDetails(track spotify.FullTrack) TrackInfo {
+// GetShortTrackDetails builds the track's info in the requested form. Provenance is only
+// attached to the full form, and may be nil when the caller doesn't track sources.
+func GetShortTrackDetails(track spotify.FullTrack, fields string, provenance *TrackProvenance) TrackInfo {
 	artistNames := make([]string, len(track.Artists))
 	for i, artist := range track.Artists {
 		artistNames[i] = artist.Name
 	}
 
-	return TrackInfo{
+	info := TrackInfo{
 		TrackID:     track.ID.String(),
 		TrackName:   track.Name,
 		Artists:     artistNames,
 		AlbumName:   track.Album.Name,
 		ReleaseDate: track.Album.ReleaseDate,
 		Popularity:  int(track.Popularity),
+		Playable:    track.IsPlayable,
 	}
+	if fields != TrackFieldsFull {
+		return info
+	}
+
+	artistIDs := make([]string, len(track.Artists))
+	for i, artist := range track.Artists {
+		artistIDs[i] = artist.ID.String()
+	}
+	explicit := track.Explicit
+
+	info.DurationMs = int(track.Duration)
+	info.Explicit = &explicit
+	info.ISRC = track.ExternalIDs["isrc"]
+	info.TrackNumber = int(track.TrackNumber)
+	info.ExternalURL = track.ExternalURLs["spotify"]
+	info.AlbumID = track.Album.ID.String()
+	info.AlbumType = track.Album.AlbumType
+	info.ArtistIDs = artistIDs
+	info.Provenance = provenance
+	info.LinkedFromID = string(linkedFromID(track))
+
+	return info
 }
 
-func GetAllUserSavedTracks(ctx context.Context, client *spotify.Client) ([]spotify.FullTrack, error) {
-	var allTracks []spotify.FullTrack
+func GetAllUserSavedTrackItems(ctx context.Context, client *spotify.Client) ([]spotify.SavedTrack, error) {
+	var allTracks []spotify.SavedTrack
 
-	page, err := client.CurrentUsersTracks(ctx)
+	page, err := client.CurrentUsersTracks(ctx, marketOptions(ctx)...)
 	if err != nil {
 		return nil, err
 	}
-
-	for _, track := range page.Tracks {
-		allTracks = append(allTracks, track.FullTrack)
-	}
+	allTracks = append(allTracks, page.Tracks...)
 
 	for {
 		err := client.NextPage(ctx, page)
@@ -84,12 +152,24 @@ func GetAllUserSavedTracks(ctx context.Context, client *spotify.Client) ([]spoti
 			zap.L().Warn("Failed to fetch next page of user saved tracks", zap.Error(err))
 			break
 		}
-		for _, track := range page.Tracks {
-			allTracks = append(allTracks, track.FullTrack)
-		}
+		allTracks = append(allTracks, page.Tracks...)
 	}
 
 	zap.L().Info("Fetched all user saved tracks", zap.Int("count", len(allTracks)))
 
 	return allTracks, nil
 }
+
+func GetAllUserSavedTracks(ctx context.Context, client *spotify.Client) ([]spotify.FullTrack, error) {
+	saved, err := GetAllUserSavedTrackItems(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	allTracks := make([]spotify.FullTrack, 0, len(saved))
+	for _, track := range saved {
+		allTracks = append(allTracks, track.FullTrack)
+	}
+
+	return allTracks, nil
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -9,6 +10,10 @@ import (
 	"go.uber.org/zap"
 )
 
+// ErrNoUserID is returned instead of reading or writing a user's files when their Spotify
+// user ID isn't known, so users whose profile couldn't be fetched don't share one file
+var ErrNoUserID = errors.New("no Spotify user ID")
+
 func WriteJsonObjectToFile(obj interface{}, filename string) error {
 
 	// Ensure the "files" directory exists
@@ -40,3 +45,23 @@ func WriteJsonObjectToFile(obj interface{}, filename string) error {
 
 	return nil
 }
+
+func ReadJsonObjectFromFile(obj interface{}, filename string) error {
+	if !strings.HasSuffix(filename, ".json") {
+		filename = fmt.Sprintf("%s.json", filename)
+	}
+	path := fmt.Sprintf("./files/%s", filename)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := json.NewDecoder(file).Decode(obj); err != nil {
+		zap.L().Error("failed to read object", zap.String("path", path), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"sync"
 
 	"github.com/joho/godotenv"
 	spotify "github.com/zmb3/spotify/v2"
@@ -15,7 +16,17 @@ import (
 
 type ctxKey string
 
-const spotifyClientKey ctxKey = "spotifyClient"
+const (
+	spotifyClientKey ctxKey = "spotifyClient"
+	currentUserKey   ctxKey = "currentUser"
+)
+
+// currentUser is the user's profile, fetched the first time a request needs it and shared
+// by everything that reads the user's name, ID or market
+type currentUser struct {
+	once sync.Once
+	user *spotify.PrivateUser
+}
 
 var (
 	authenticator *spotifyauthpkg.Authenticator
@@ -42,8 +53,11 @@ func Init() {
 			spotifyauthpkg.ScopePlaylistModifyPrivate,
 			spotifyauthpkg.ScopePlaylistModifyPublic,
 			spotifyauthpkg.ScopePlaylistReadPrivate,
+			spotifyauthpkg.ScopePlaylistReadCollaborative,
 			spotifyauthpkg.ScopeUserLibraryRead,
 			spotifyauthpkg.ScopeUserTopRead,
+			spotifyauthpkg.ScopeUserFollowRead,
+			spotifyauthpkg.ScopeImageUpload,
 		),
 	)
 }
@@ -93,6 +107,7 @@ func SpotifyAuthMiddleware(next http.Handler) http.Handler {
 
 		// Attach client to context
 		ctx := context.WithValue(r.Context(), spotifyClientKey, client)
+		ctx = context.WithValue(ctx, currentUserKey, &currentUser{})
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -103,13 +118,34 @@ func ClientFromContext(ctx context.Context) *spotify.Client {
 	return client
 }
 
-func UserNameFromContext(ctx context.Context) string {
+// currentUserFromContext returns the user's profile, fetched at most once per request, or
+// nil if it couldn't be fetched
+func currentUserFromContext(ctx context.Context) *spotify.PrivateUser {
 	client := ClientFromContext(ctx)
 	if client == nil {
-		return ""
+		return nil
+	}
+	c, ok := ctx.Value(currentUserKey).(*currentUser)
+	if !ok {
+		return fetchCurrentUser(ctx, client)
 	}
+	c.once.Do(func() {
+		c.user = fetchCurrentUser(ctx, client)
+	})
+	return c.user
+}
+
+func fetchCurrentUser(ctx context.Context, client *spotify.Client) *spotify.PrivateUser {
 	user, err := client.CurrentUser(ctx)
 	if err != nil {
+		return nil
+	}
+	return user
+}
+
+func UserNameFromContext(ctx context.Context) string {
+	user := currentUserFromContext(ctx)
+	if user == nil {
 		return ""
 	}
 
@@ -117,14 +153,24 @@ func UserNameFromContext(ctx context.Context) string {
 }
 
 func UserIDFromContext(ctx context.Context) string {
-	client := ClientFromContext(ctx)
-	if client == nil {
-		return ""
-	}
-	user, err := client.CurrentUser(ctx)
-	if err != nil {
+	user := currentUserFromContext(ctx)
+	if user == nil {
 		return ""
 	}
 
 	return user.ID
 }
+
+// MarketFromContext returns the country from the user's profile, so track fetches can ask
+// for versions playable there. Falls back to letting Spotify work the market out from the
+// token.
+func MarketFromContext(ctx context.Context) string {
+	if ClientFromContext(ctx) == nil {
+		return ""
+	}
+	user := currentUserFromContext(ctx)
+	if user == nil || user.Country == "" {
+		return spotify.MarketFromToken
+	}
+	return user.Country
+}